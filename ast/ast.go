@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"strconv"
 	"strings"
 
 	"github.com/wawoon/monkeylang/token"
@@ -10,6 +11,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
 }
 
 type Statement interface {
@@ -22,8 +24,20 @@ type Expression interface {
 	expressionNode()
 }
 
+// Pattern is the target of a destructuring let: an *Identifier, an
+// *ArrayPattern or a *HashPattern. The patterns of a match arm may also be
+// or contain a *LiteralPattern.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
 type Program struct {
 	Statements []Statement
+
+	// Comments holds every comment in the source in order. They take no part
+	// in evaluation and are kept for tools such as formatters and linters.
+	Comments []*Comment
 }
 
 func (p *Program) TokenLiteral() string {
@@ -34,6 +48,13 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -42,18 +63,43 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+type Comment struct {
+	Token token.Token // the COMMENT token, including its delimiters
+}
+
+func (c *Comment) TokenLiteral() string { return c.Token.Literal }
+func (c *Comment) Pos() token.Position  { return c.Token.Pos }
+func (c *Comment) String() string       { return c.Token.Literal }
+
+// Text returns the comment with its delimiters removed.
+func (c *Comment) Text() string {
+	text := c.Token.Literal
+	if strings.HasPrefix(text, "//") {
+		return text[2:]
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+}
+
+// LetStatement binds Value to Name or, when destructuring, to the names in
+// Pattern. Exactly one of Name and Pattern is set.
 type LetStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token
+	Name    *Identifier
+	Pattern Pattern
+	Value   Expression
 }
 
 func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
-	return "let " + ls.Name.String() + " = " + ls.Value.String() + ";"
+	target := Node(ls.Name)
+	if ls.Pattern != nil {
+		target = ls.Pattern
+	}
+	return "let " + target.String() + " = " + ls.Value.String() + ";"
 }
 
 type ReturnStatement struct {
@@ -65,6 +111,7 @@ func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
 func (rs *ReturnStatement) String() string {
 	if rs.ReturnValue != nil {
 		return "return " + rs.ReturnValue.String() + ";"
@@ -73,6 +120,65 @@ func (rs *ReturnStatement) String() string {
 	}
 }
 
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) String() string {
+	return "while" + ws.Condition.String() + " " + ws.Body.String()
+}
+
+// ForStatement is a for-in loop: for (value in iterable) { ... } or
+// for (key, value in iterable) { ... }. Key is nil in the first form.
+type ForStatement struct {
+	Token    token.Token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String())
+		out.WriteString(", ")
+	}
+	out.WriteString(fs.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) String() string       { return "break;" }
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) String() string       { return "continue;" }
+
 type ExpressionStatement struct {
 	Token      token.Token // the first token of the expression
 	Expression Expression
@@ -82,6 +188,7 @@ func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
+func (es *ExpressionStatement) Pos() token.Position { return es.Token.Pos }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -98,7 +205,9 @@ type Identifier struct {
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
-func (i *Identifier) expressionNode() {}
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+func (i *Identifier) expressionNode()     {}
+func (i *Identifier) patternNode()        {}
 func (i *Identifier) String() string {
 	return i.Value
 }
@@ -111,6 +220,7 @@ type IntegerLiteral struct {
 func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
 
 func (il *IntegerLiteral) expressionNode() {}
 
@@ -118,6 +228,16 @@ func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -126,7 +246,8 @@ type Boolean struct {
 func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
-func (b *Boolean) expressionNode() {}
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
+func (b *Boolean) expressionNode()     {}
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -139,11 +260,49 @@ type StringLiteral struct {
 func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
-func (sl *StringLiteral) expressionNode() {}
+func (sl *StringLiteral) Pos() token.Position { return sl.Token.Pos }
+func (sl *StringLiteral) expressionNode()     {}
 func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
 
+type CharLiteral struct {
+	Token token.Token
+	Value rune
+}
+
+func (cl *CharLiteral) TokenLiteral() string { return cl.Token.Literal }
+func (cl *CharLiteral) Pos() token.Position  { return cl.Token.Pos }
+func (cl *CharLiteral) expressionNode()      {}
+func (cl *CharLiteral) String() string       { return strconv.QuoteRune(cl.Value) }
+
+// InterpolatedString is a string literal with embedded expressions such as
+// "Hello ${name}!". Parts alternates between *StringLiteral segments and the
+// embedded expressions, in source order; empty segments are left out.
+type InterpolatedString struct {
+	Token token.Token // the INTERP_START token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+	out.WriteString(`"`)
+	for _, part := range is.Parts {
+		if s, ok := part.(*StringLiteral); ok {
+			out.WriteString(s.Value)
+			continue
+		}
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+	out.WriteString(`"`)
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -153,7 +312,8 @@ type PrefixExpression struct {
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
-func (pe *PrefixExpression) expressionNode() {}
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
+func (pe *PrefixExpression) expressionNode()     {}
 func (pe *PrefixExpression) String() string {
 	return "(" + pe.Token.Literal + pe.Right.String() + ")"
 }
@@ -168,11 +328,49 @@ type InfixExpression struct {
 func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
-func (ie *InfixExpression) expressionNode() {}
+func (ie *InfixExpression) Pos() token.Position { return ie.Token.Pos }
+func (ie *InfixExpression) expressionNode()     {}
 func (ie *InfixExpression) String() string {
 	return "(" + ie.Left.String() + " " + ie.Token.Literal + " " + ie.Right.String() + ")"
 }
 
+// AssignExpression is x = value or a compound assignment such as x += value.
+// Target is an *Identifier, an *IndexExpression, as in xs[0] = value, or a
+// *MemberExpression, as in h.name = value.
+// Operator is the literal of the assignment token.
+type AssignExpression struct {
+	Token    token.Token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) Pos() token.Position { return ae.Token.Pos }
+func (ae *AssignExpression) expressionNode()     {}
+func (ae *AssignExpression) String() string {
+	return "(" + ae.Target.String() + " " + ae.Operator + " " + ae.Value.String() + ")"
+}
+
+// PipeExpression is left |> right. It calls right with left as the first
+// argument: x |> f(a) is f(x, a) and x |> f is f(x).
+type PipeExpression struct {
+	Token token.Token // |>
+	Left  Expression
+	Right Expression
+}
+
+func (pe *PipeExpression) TokenLiteral() string {
+	return pe.Token.Literal
+}
+func (pe *PipeExpression) Pos() token.Position { return pe.Token.Pos }
+func (pe *PipeExpression) expressionNode()     {}
+func (pe *PipeExpression) String() string {
+	return "(" + pe.Left.String() + " |> " + pe.Right.String() + ")"
+}
+
 type IfExpression struct {
 	Token       token.Token
 	Condition   Expression
@@ -183,7 +381,8 @@ type IfExpression struct {
 func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
-func (ie *IfExpression) expressionNode() {}
+func (ie *IfExpression) Pos() token.Position { return ie.Token.Pos }
+func (ie *IfExpression) expressionNode()     {}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -206,7 +405,8 @@ type BlockStatement struct {
 func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
-func (bs *BlockStatement) statementNode() {}
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BlockStatement) statementNode()      {}
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -215,16 +415,24 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+// FunctionLiteral is fn(a, b = 10, ...rest) { body }. Defaults holds the
+// default value of each parameter, or nil for a required one; parameters
+// with a default always follow the required ones. Rest, if set, collects the
+// arguments beyond Parameters into an array. The arrow functions
+// (a, b) => a + b and a => a + 1 are FunctionLiterals too, with a fn Token.
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
+	Defaults   []Expression
+	Rest       *Identifier
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
-func (fl *FunctionLiteral) expressionNode() {}
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
+func (fl *FunctionLiteral) expressionNode()     {}
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	out.WriteString(fl.TokenLiteral())
@@ -234,22 +442,43 @@ func (fl *FunctionLiteral) String() string {
 			out.WriteString(", ")
 		}
 		out.WriteString(p.String())
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			out.WriteString(" = ")
+			out.WriteString(fl.Defaults[i].String())
+		}
+	}
+	if fl.Rest != nil {
+		if len(fl.Parameters) != 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString("...")
+		out.WriteString(fl.Rest.String())
 	}
 	out.WriteString(") ")
 	out.WriteString(fl.Body.String())
 	return out.String()
 }
 
+// CallExpression is f(a, b, name: c). Named arguments always follow the
+// positional ones.
 type CallExpression struct {
-	Token     token.Token
-	Function  Expression
-	Arguments []Expression
+	Token          token.Token
+	Function       Expression
+	Arguments      []Expression
+	NamedArguments []NamedArgument
+}
+
+// NamedArgument is a single name: value argument of a CallExpression.
+type NamedArgument struct {
+	Name  *Identifier
+	Value Expression
 }
 
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
-func (ce *CallExpression) expressionNode() {}
+func (ce *CallExpression) Pos() token.Position { return ce.Token.Pos }
+func (ce *CallExpression) expressionNode()     {}
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -257,6 +486,9 @@ func (ce *CallExpression) String() string {
 	for _, a := range ce.Arguments {
 		args = append(args, a.String())
 	}
+	for _, a := range ce.NamedArguments {
+		args = append(args, a.Name.String()+": "+a.Value.String())
+	}
 
 	out.WriteString(ce.Function.String())
 	out.WriteString("(")
@@ -273,7 +505,8 @@ type ArrayLiteral struct {
 func (al *ArrayLiteral) TokenLiteral() string {
 	return al.Token.Literal
 }
-func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos }
+func (al *ArrayLiteral) expressionNode()     {}
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	out.WriteString("[")
@@ -295,31 +528,177 @@ type IndexExpression struct {
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IndexExpression) String() string {
 	return "(" + ie.Left.String() + "[" + ie.Index.String() + "])"
 }
 
+// MemberExpression is object.name. It reads a field of a hash and, as the
+// function of a CallExpression, calls a method: xs.push(1) is push(xs, 1).
+type MemberExpression struct {
+	Token    token.Token // .
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Property.String() + ")"
+}
+
 type HashLiteral struct {
 	Token token.Token // {
-	Pairs map[Expression]Expression
+	Pairs []HashPair  // in source order
+}
+
+// HashPair is a single key: value entry of a HashLiteral.
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	out.WriteString("{")
 
-	i := 0
-	for k, v := range hl.Pairs {
+	for i, pair := range hl.Pairs {
 		if i != 0 {
 			out.WriteString(",")
 		}
-		out.WriteString(k.String())
+		out.WriteString(pair.Key.String())
 		out.WriteString(":")
-		out.WriteString(v.String())
-		i++
+		out.WriteString(pair.Value.String())
 	}
 	out.WriteString("}")
 	return out.String()
 }
+
+// ArrayPattern is [a, b = 2, ...rest] on the left of a destructuring let.
+type ArrayPattern struct {
+	Token    token.Token // [
+	Elements []PatternElement
+	Rest     *Identifier
+}
+
+// PatternElement is one target of an ArrayPattern with its optional default
+// value, used when the array is too short.
+type PatternElement struct {
+	Target  Pattern
+	Default Expression
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() token.Position  { return ap.Token.Pos }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, e := range ap.Elements {
+		element := e.Target.String()
+		if e.Default != nil {
+			element += " = " + e.Default.String()
+		}
+		elements = append(elements, element)
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPattern is {host, port: p = 80} on the left of a destructuring let.
+// Each key names a string key of the hash, either as an identifier or as a
+// string literal, as in {"content-type": ct}.
+type HashPattern struct {
+	Token token.Token // {
+	Pairs []HashPatternPair
+}
+
+// HashPatternPair binds the value of Key to Target, which is the identifier
+// Key itself in the shorthand {key}. Default is used when Key is missing.
+// Key is an *Identifier or a *StringLiteral.
+type HashPatternPair struct {
+	Key     Expression
+	Target  Pattern
+	Default Expression
+}
+
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Pos() token.Position  { return hp.Token.Pos }
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for _, p := range hp.Pairs {
+		pair := p.Key.String()
+		shorthand := false
+		switch key := p.Key.(type) {
+		case *StringLiteral:
+			pair = strconv.Quote(key.Value)
+		case *Identifier:
+			target, ok := p.Target.(*Identifier)
+			shorthand = ok && target.Value == key.Value
+		}
+		if !shorthand {
+			pair += ": " + p.Target.String()
+		}
+		if p.Default != nil {
+			pair += " = " + p.Default.String()
+		}
+		pairs = append(pairs, pair)
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// LiteralPattern matches a value equal to Value, a literal such as 0, -1.5,
+// "user", 'c' or true.
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Value.TokenLiteral() }
+func (lp *LiteralPattern) Pos() token.Position  { return lp.Value.Pos() }
+func (lp *LiteralPattern) String() string {
+	// quoted so that a string pattern does not read as a binding
+	if str, ok := lp.Value.(*StringLiteral); ok {
+		return strconv.Quote(str.Value)
+	}
+	return lp.Value.String()
+}
+
+// MatchExpression is match (value) { pattern if guard => result, ... }. The
+// first arm whose pattern matches and whose guard, if any, is truthy gives
+// the result.
+type MatchExpression struct {
+	Token token.Token // match
+	Value Expression
+	Arms  []*MatchArm
+}
+
+// MatchArm is a single arm of a MatchExpression. The names bound by Pattern
+// are visible in Guard and Body. An arm whose result is a single expression
+// has a Body holding just that expression.
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression
+	Body    *BlockStatement
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		a := arm.Pattern.String()
+		if arm.Guard != nil {
+			a += " if " + arm.Guard.String()
+		}
+		arms = append(arms, a+" => "+arm.Body.String())
+	}
+	return "match (" + me.Value.String() + ") { " + strings.Join(arms, ", ") + " }"
+}
@@ -2,6 +2,9 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
 
 	"github.com/wawoon/monkeylang/object"
 )
@@ -15,7 +18,7 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return object.MakeInt(int64(len(arg.Value)))
+				return object.MakeInt(int64(utf8.RuneCountInString(arg.Value)))
 			case *object.Array:
 				return object.MakeInt(int64(len(arg.Elements)))
 			case *object.Null:
@@ -90,6 +93,68 @@ var builtins = map[string]*object.Builtin{
 			}
 		},
 	},
+	"char": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Char:
+				return arg
+			case *object.Integer:
+				if arg.Value < 0 || arg.Value > utf8.MaxRune || !utf8.ValidRune(rune(arg.Value)) {
+					return newError("invalid code point for `char`: %d", arg.Value)
+				}
+				return &object.Char{Value: rune(arg.Value)}
+			case *object.String:
+				if utf8.RuneCountInString(arg.Value) != 1 {
+					return newError("argument to `char` must be a single character, got %q", arg.Value)
+				}
+				ch, _ := utf8.DecodeRuneInString(arg.Value)
+				return &object.Char{Value: ch}
+			default:
+				return newError("argument to `char` not supported, got %s", arg.Type())
+			}
+		},
+	},
+	"int": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Char:
+				return object.MakeInt(int64(arg.Value))
+			case *object.Float:
+				if !(arg.Value >= math.MinInt64 && arg.Value < math.MaxInt64) {
+					return newError("cannot convert %s to an integer", arg.Inspect())
+				}
+				return object.MakeInt(int64(arg.Value))
+			default:
+				return newError("argument to `int` not supported, got %s", arg.Type())
+			}
+		},
+	},
+	"str": {
+		Options: []string{"base"},
+		OptionsFn: func(options map[string]object.Object, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if base, ok := options["base"]; ok {
+				return formatInteger(args[0], base)
+			}
+			if str, ok := args[0].(*object.String); ok {
+				return str
+			}
+			return &object.String{Value: args[0].Inspect()}
+		},
+	},
 	"puts": {
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
@@ -99,3 +164,16 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 }
+
+// formatInteger implements str(x, base: n) for bases 2 to 36.
+func formatInteger(x object.Object, base object.Object) object.Object {
+	integer, ok := x.(*object.Integer)
+	if !ok {
+		return newError("argument to `str` with base must be INTEGER, got %s", x.Type())
+	}
+	b, ok := base.(*object.Integer)
+	if !ok || b.Value < 2 || b.Value > 36 {
+		return newError("base for `str` must be an integer from 2 to 36, got %s", base.Inspect())
+	}
+	return &object.String{Value: strconv.FormatInt(integer.Value, int(b.Value))}
+}
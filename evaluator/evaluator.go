@@ -1,16 +1,23 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/wawoon/monkeylang/ast"
 	"github.com/wawoon/monkeylang/object"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
@@ -21,80 +28,108 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+	case *ast.CharLiteral:
+		return &object.Char{Value: node.Value}
 	case *ast.Boolean:
 		return naiveBoolToBooleanObject(node.Value)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return withPos(evalPrefixExpression(node.Operator, right), node)
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isAbrupt(left) {
 			return left
 		}
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return withPos(evalInfixExpression(node.Operator, left, right), node)
+	case *ast.AssignExpression:
+		return withPos(evalAssignExpression(node, env), node)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.MatchExpression:
+		return withPos(evalMatchExpression(node, env), node)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		if isAbrupt(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
-		if isError(val) {
+		if isAbrupt(val) {
+			return val
+		}
+		if node.Pattern != nil {
+			if err := bindPattern(node.Pattern, val, env); err != nil {
+				return withPos(err, node.Pattern)
+			}
 			return val
 		}
 		env.Set(node.Name.Value, val)
 		return val
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return withPos(evalIdentifier(node, env), node)
 	case *ast.FunctionLiteral:
 		return &object.Function{
 			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
 			Body:       node.Body,
 			Env:        env,
 		}
 	case *ast.CallExpression:
-		fn := Eval(node.Function, env)
-		if isError(fn) {
-			return fn
-		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-
-		return applyFunction(fn, args)
+		return withPos(evalCallExpression(node, env), node)
+	case *ast.PipeExpression:
+		return withPos(evalPipeExpression(node, env), node)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isAbrupt(left) {
 			return left
 		}
 		index := Eval(node.Index, env)
-		if isError(index) {
+		if isAbrupt(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return withPos(evalIndexExpression(left, index), node)
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if isAbrupt(obj) {
+			return obj
+		}
+		return withPos(evalMemberExpression(obj, node.Property.Value), node)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return withPos(evalHashLiteral(node, env), node)
 	}
 
 	return nil
@@ -123,13 +158,14 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 }
 
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
-	var result object.Object
+	// an empty block evaluates to null
+	var result object.Object = NULL
 	for _, stmt := range block.Statements {
 		result = Eval(stmt, env)
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_OBJECT || rt == object.ERROR_OBJECT {
+			if rt == object.RETURN_OBJECT || rt == object.ERROR_OBJECT || rt == object.BREAK_OBJECT || rt == object.CONTINUE_OBJECT {
 				return result
 			}
 		}
@@ -149,12 +185,329 @@ func evalIdentifier(ident *ast.Identifier, env *object.Environment) object.Objec
 	return newError("identifier not found: %s", ident.Value)
 }
 
+// bindPattern binds the names in pattern to the matching parts of val in env.
+// Array patterns require an array with enough elements for every target
+// without a default, and no more unless there is a rest element. Hash
+// patterns require a hash with every key that has no default; other keys are
+// ignored. It returns an error if val does not have the shape of pattern,
+// or the result of a default value that fails, breaks or returns.
+func bindPattern(pattern ast.Pattern, val object.Object, env *object.Environment) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		env.Set(pattern.Value, val)
+		return nil
+	case *ast.ArrayPattern:
+		return bindArrayPattern(pattern, val, env)
+	case *ast.HashPattern:
+		return bindHashPattern(pattern, val, env)
+	default:
+		return newError("unknown pattern: %s", pattern.String())
+	}
+}
+
+func bindArrayPattern(pattern *ast.ArrayPattern, val object.Object, env *object.Environment) object.Object {
+	array, ok := val.(*object.Array)
+	if !ok {
+		return newError("cannot destructure %s with an array pattern", val.Type())
+	}
+
+	required := 0
+	for i, element := range pattern.Elements {
+		if element.Default == nil {
+			required = i + 1
+		}
+	}
+	if len(array.Elements) < required {
+		return newError("not enough elements to destructure: got %d, want at least %d", len(array.Elements), required)
+	}
+	if pattern.Rest == nil && len(array.Elements) > len(pattern.Elements) {
+		return newError("too many elements to destructure: got %d, want at most %d", len(array.Elements), len(pattern.Elements))
+	}
+
+	for i, element := range pattern.Elements {
+		var value object.Object
+		if i < len(array.Elements) {
+			value = array.Elements[i]
+		} else {
+			value = Eval(element.Default, env)
+			if isAbrupt(value) {
+				return value
+			}
+		}
+		if err := bindPattern(element.Target, value, env); err != nil {
+			return err
+		}
+	}
+
+	if pattern.Rest != nil {
+		rest := []object.Object{}
+		if len(array.Elements) > len(pattern.Elements) {
+			rest = append(rest, array.Elements[len(pattern.Elements):]...)
+		}
+		env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+	}
+	return nil
+}
+
+func bindHashPattern(pattern *ast.HashPattern, val object.Object, env *object.Environment) object.Object {
+	hash, ok := val.(*object.Hash)
+	if !ok {
+		return newError("cannot destructure %s with a hash pattern", val.Type())
+	}
+
+	for _, pair := range pattern.Pairs {
+		key := hashPatternKey(pair)
+		var value object.Object
+		if found, ok := hash.Pairs[key.HashKey()]; ok {
+			value = found.Value
+		} else if pair.Default != nil {
+			value = Eval(pair.Default, env)
+			if isAbrupt(value) {
+				return value
+			}
+		} else {
+			return newError("key %s not found in hash", key.Value)
+		}
+		if err := bindPattern(pair.Target, value, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashPatternKey(pair ast.HashPatternPair) *object.String {
+	if str, ok := pair.Key.(*ast.StringLiteral); ok {
+		return &object.String{Value: str.Value}
+	}
+	return &object.String{Value: pair.Key.(*ast.Identifier).Value}
+}
+
+// evalMatchExpression tries the arms of me in order and evaluates the body
+// of the first one that matches. Each arm is tried in its own environment,
+// so names bound by a pattern that fails to match, or by another arm, are
+// not visible in the body.
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	val := Eval(me.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	for _, arm := range me.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+		matched, err := matchPattern(arm.Pattern, val, armEnv)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isAbrupt(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		return Eval(arm.Body, armEnv)
+	}
+
+	return newError("no match arm for %s", val.Inspect())
+}
+
+// matchPattern reports whether val has the shape of pattern, binding the
+// names in pattern in env as it goes. Unlike bindPattern it fails quietly:
+// a mismatch is not an error. The identifier _ matches anything and binds
+// nothing. Errors, and breaks or returns, only come from evaluating default
+// values.
+func matchPattern(pattern ast.Pattern, val object.Object, env *object.Environment) (bool, object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			env.Set(pattern.Value, val)
+		}
+		return true, nil
+	case *ast.LiteralPattern:
+		literal := Eval(pattern.Value, env)
+		if isAbrupt(literal) {
+			return false, literal
+		}
+		return objectsEqual(literal, val), nil
+	case *ast.ArrayPattern:
+		array, ok := val.(*object.Array)
+		if !ok {
+			return false, nil
+		}
+		if len(array.Elements) > len(pattern.Elements) && pattern.Rest == nil {
+			return false, nil
+		}
+		for i, element := range pattern.Elements {
+			var value object.Object
+			if i < len(array.Elements) {
+				value = array.Elements[i]
+			} else if element.Default != nil {
+				value = Eval(element.Default, env)
+				if isAbrupt(value) {
+					return false, value
+				}
+			} else {
+				return false, nil
+			}
+			if matched, err := matchPattern(element.Target, value, env); !matched || err != nil {
+				return false, err
+			}
+		}
+		if pattern.Rest != nil {
+			rest := []object.Object{}
+			if len(array.Elements) > len(pattern.Elements) {
+				rest = append(rest, array.Elements[len(pattern.Elements):]...)
+			}
+			env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+		}
+		return true, nil
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return false, nil
+		}
+		for _, pair := range pattern.Pairs {
+			var value object.Object
+			if found, ok := hash.Pairs[hashPatternKey(pair).HashKey()]; ok {
+				value = found.Value
+			} else if pair.Default != nil {
+				value = Eval(pair.Default, env)
+				if isAbrupt(value) {
+					return false, value
+				}
+			} else {
+				return false, nil
+			}
+			if matched, err := matchPattern(pair.Target, value, env); !matched || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	default:
+		return false, newError("unknown pattern: %s", pattern.String())
+	}
+}
+
+// objectsEqual reports whether a literal pattern value equals val. Values of
+// different types are never equal, except integers and floats of the same
+// numeric value.
+func objectsEqual(a, b object.Object) bool {
+	if a.Type() != b.Type() && !(isNumber(a) && isNumber(b)) {
+		return false
+	}
+	return evalInfixExpression("==", a, b) == TRUE
+}
+
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+		return evalIndexAssignment(left, index, node, env)
+	case *ast.MemberExpression:
+		obj := Eval(target.Object, env)
+		if isAbrupt(obj) {
+			return obj
+		}
+		name := target.Property.Value
+		if _, ok := obj.(*object.Hash); !ok {
+			return newError("%s has no field %s", obj.Type(), name)
+		}
+		// h.name = value is h["name"] = value
+		return evalIndexAssignment(obj, &object.String{Value: name}, node, env)
+	}
+
+	name := node.Target.(*ast.Identifier).Value
+	current, ok := env.Get(name)
+	if !ok {
+		return newError("assignment to undefined variable: %s", name)
+	}
+
+	val := Eval(node.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	if node.Operator != "=" {
+		// x += y is x = x + y
+		operator := strings.TrimSuffix(node.Operator, "=")
+		val = evalInfixExpression(operator, current, val)
+		if isAbrupt(val) {
+			return val
+		}
+	}
+
+	env.Assign(name, val)
+	return val
+}
+
+// evalIndexAssignment stores a value into an array element or a hash entry in
+// place. Arrays and hashes are shared by reference, not copied: after
+// let b = a; b[0] = 1, a[0] is 1 too, and a function that assigns to an
+// element of its argument changes the caller's value. Builtins such as push
+// still return a new array and leave their argument untouched.
+func evalIndexAssignment(left, index object.Object, node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return newError("index out of range: %d with length %d", i.Value, len(left.Elements))
+		}
+	case *object.Hash:
+		if _, ok := index.(object.Hashable); !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+
+	val := Eval(node.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	if node.Operator != "=" {
+		current := evalIndexExpression(left, index)
+		val = evalInfixExpression(strings.TrimSuffix(node.Operator, "="), current, val)
+		if isAbrupt(val) {
+			return val
+		}
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		left.Elements[index.(*object.Integer).Value] = val
+	case *object.Hash:
+		left.Set(index.(object.Hashable).HashKey(), object.HashPair{Key: index, Value: val})
+	}
+	return val
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		if integer, ok := right.(*object.Integer); ok {
+			return &object.Integer{Value: ^integer.Value}
+		}
 	}
 	return newError("unknown operator: %s%s", operator, right.Type())
 }
@@ -173,20 +526,28 @@ func evalBangExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJECT {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	rightValue := right.(*object.Integer).Value
-	return &object.Integer{Value: -rightValue}
 }
 
 func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJECT && right.Type() == object.INTEGER_OBJECT:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJECT && right.Type() == object.STRING_OBJECT:
 		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.CHAR_OBJECT && right.Type() == object.CHAR_OBJECT:
+		return evalCharInfixExpression(operator, left, right)
+	case operator == "+" && isText(left) && isText(right):
+		return &object.String{Value: left.Inspect() + right.Inspect()}
 	case operator == "==":
 		return naiveBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -210,9 +571,36 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "*":
 		return &object.Integer{Value: leftValue * rightValue}
 	case "/":
+		if rightValue == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftValue / rightValue}
 	case "%":
+		if rightValue == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftValue % rightValue}
+	case "**":
+		if rightValue < 0 {
+			return newError("negative exponent: %d ** %d", leftValue, rightValue)
+		}
+		return &object.Integer{Value: intPow(leftValue, rightValue)}
+	case "&":
+		return &object.Integer{Value: leftValue & rightValue}
+	case "|":
+		return &object.Integer{Value: leftValue | rightValue}
+	case "^":
+		return &object.Integer{Value: leftValue ^ rightValue}
+	case "<<":
+		if rightValue < 0 {
+			return newError("negative shift count: %d", rightValue)
+		}
+		return &object.Integer{Value: leftValue << uint64(rightValue)}
+	case ">>":
+		if rightValue < 0 {
+			return newError("negative shift count: %d", rightValue)
+		}
+		return &object.Integer{Value: leftValue >> uint64(rightValue)}
 	case "==":
 		return naiveBoolToBooleanObject(leftValue == rightValue)
 	case "!=":
@@ -230,6 +618,72 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	return NULL
 }
 
+// intPow computes base ** exp by repeated squaring. Like the other integer
+// operators it wraps around on overflow.
+func intPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+// evalFloatInfixExpression handles float-float and mixed int-float operands.
+// Integers are promoted to float first, so 1 == 1.0 is true. The results
+// follow IEEE 754: NaN compares unequal to everything including itself, and
+// dividing by zero gives +Inf, -Inf or NaN.
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
+	case "-":
+		return &object.Float{Value: leftValue - rightValue}
+	case "*":
+		return &object.Float{Value: leftValue * rightValue}
+	case "/":
+		return &object.Float{Value: leftValue / rightValue}
+	case "%":
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftValue, rightValue)}
+	case "==":
+		return naiveBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return naiveBoolToBooleanObject(leftValue != rightValue)
+	case "<":
+		return naiveBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return naiveBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return naiveBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return naiveBoolToBooleanObject(leftValue >= rightValue)
+	}
+
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJECT || obj.Type() == object.FLOAT_OBJECT
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	}
+	return math.NaN()
+}
+
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -245,9 +699,72 @@ func evalStringInfixExpression(operator string, left object.Object, right object
 	}
 }
 
+// evalLogicalExpression evaluates && and || with short-circuiting: the right
+// operand is only evaluated when the left one does not decide the result.
+// The result is always a boolean derived with isTruthy.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(node.Right, env)
+	if isAbrupt(right) {
+		return right
+	}
+	return naiveBoolToBooleanObject(isTruthy(right))
+}
+
+func evalInterpolatedString(str *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out bytes.Buffer
+	for _, part := range str.Parts {
+		value := Eval(part, env)
+		if isAbrupt(value) {
+			return value
+		}
+		out.WriteString(value.Inspect())
+	}
+	return &object.String{Value: out.String()}
+}
+
+func evalCharInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal := left.(*object.Char).Value
+	rightVal := right.(*object.Char).Value
+	switch operator {
+	case "+":
+		return &object.String{Value: string(leftVal) + string(rightVal)}
+	case "==":
+		return naiveBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return naiveBoolToBooleanObject(leftVal != rightVal)
+	case "<":
+		return naiveBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return naiveBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return naiveBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return naiveBoolToBooleanObject(leftVal >= rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// isText reports whether obj is a string or a char, which can be joined
+// with + into a new string.
+func isText(obj object.Object) bool {
+	return obj.Type() == object.STRING_OBJECT || obj.Type() == object.CHAR_OBJECT
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+	if isAbrupt(condition) {
 		return condition
 	}
 
@@ -260,11 +777,92 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := Eval(ws.Body, env)
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_OBJECT, object.ERROR_OBJECT:
+				return result
+			case object.BREAK_OBJECT:
+				return NULL
+			}
+		}
+	}
+}
+
+// evalForStatement runs the body once per element of an array, pair of a
+// hash (in insertion order) or character of a string. With a single loop
+// variable it is bound to the element, the hash key or the character; with
+// two, the first one is bound to the index (or key) and the second to the
+// element (or value). Each iteration gets its own environment, so closures
+// created in the body capture that iteration's values.
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+
+	var keys, values []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		for i, element := range iterable.Elements {
+			keys = append(keys, object.MakeInt(int64(i)))
+			values = append(values, element)
+		}
+	case *object.String:
+		i := 0
+		for _, ch := range iterable.Value {
+			keys = append(keys, object.MakeInt(int64(i)))
+			values = append(values, &object.Char{Value: ch})
+			i++
+		}
+	case *object.Hash:
+		for _, key := range iterable.Keys {
+			pair := iterable.Pairs[key]
+			keys = append(keys, pair.Key)
+			values = append(values, pair.Value)
+		}
+		if fs.Key == nil {
+			values = keys
+		}
+	default:
+		return withPos(newError("cannot iterate over %s", iterable.Type()), fs.Iterable)
+	}
+
+	for i := range values {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		if fs.Key != nil {
+			loopEnv.Set(fs.Key.Value, keys[i])
+		}
+		loopEnv.Set(fs.Value.Value, values[i])
+
+		result := Eval(fs.Body, loopEnv)
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_OBJECT, object.ERROR_OBJECT:
+				return result
+			case object.BREAK_OBJECT:
+				return NULL
+			}
+		}
+	}
+	return NULL
+}
+
 func evalExpressions(expressions []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 	for _, expression := range expressions {
 		evaluated := Eval(expression, env)
-		if isError(evaluated) {
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 
@@ -277,6 +875,8 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 	switch {
 	case left.Type() == object.ARRAY_OBJECT && index.Type() == object.INTEGER_OBJECT:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJECT && index.Type() == object.INTEGER_OBJECT:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJECT:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -294,6 +894,26 @@ func evalArrayIndexExpression(array object.Object, index object.Object) object.O
 	return arrayValue[indexValue]
 }
 
+// evalStringIndexExpression indexes a string by rune, not by byte, so
+// "日本"[1] is '本', and gives null past either end. It decodes only the
+// runes up to index rather than the whole string.
+func evalStringIndexExpression(str object.Object, index object.Object) object.Object {
+	s := str.(*object.String).Value
+	indexValue := index.(*object.Integer).Value
+	if indexValue < 0 {
+		return NULL
+	}
+
+	for i := int64(0); len(s) > 0; i++ {
+		r, size := utf8.DecodeRuneInString(s)
+		if i == indexValue {
+			return &object.Char{Value: r}
+		}
+		s = s[size:]
+	}
+	return NULL
+}
+
 func evalHashIndexExpression(hash object.Object, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 	indexValue, ok := index.(object.Hashable)
@@ -308,50 +928,251 @@ func evalHashIndexExpression(hash object.Object, index object.Object) object.Obj
 	return pair.Value
 }
 
+// evalMemberExpression evaluates obj.name, which is obj["name"] on a hash.
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return newError("%s has no field %s", obj.Type(), name)
+	}
+	return evalHashIndexExpression(hash, &object.String{Value: name})
+}
+
+// evalCallExpression evaluates call. Any leading values are passed before
+// the call's own positional arguments, as x |> f(a) passes x to f(x, a).
+func evalCallExpression(call *ast.CallExpression, env *object.Environment, leading ...object.Object) object.Object {
+	if member, ok := call.Function.(*ast.MemberExpression); ok {
+		return evalMethodCall(member, call, env, leading)
+	}
+
+	fn := Eval(call.Function, env)
+	if isAbrupt(fn) {
+		return fn
+	}
+	args, named, err := evalArguments(call, env)
+	if err != nil {
+		return err
+	}
+
+	return applyFunction(fn, append(leading, args...), named)
+}
+
+// evalPipeExpression evaluates left |> right by calling right with the value
+// of left as its first argument.
+func evalPipeExpression(pe *ast.PipeExpression, env *object.Environment) object.Object {
+	left := Eval(pe.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	if call, ok := pe.Right.(*ast.CallExpression); ok {
+		return evalCallExpression(call, env, left)
+	}
+
+	fn := Eval(pe.Right, env)
+	if isAbrupt(fn) {
+		return fn
+	}
+	return applyFunction(fn, []object.Object{left}, nil)
+}
+
+// evalMethodCall evaluates recv.name(args...), where member is the function
+// of call. If recv is a hash with a "name" field, the field's value is called
+// with args. Otherwise name must be a builtin, which is called with the
+// receiver as its first argument, so xs.push(4) is push(xs, 4) and s.len()
+// is len(s). Leading values go before args, after the receiver.
+func evalMethodCall(member *ast.MemberExpression, call *ast.CallExpression, env *object.Environment, leading []object.Object) object.Object {
+	recv := Eval(member.Object, env)
+	if isAbrupt(recv) {
+		return recv
+	}
+	args, named, err := evalArguments(call, env)
+	if err != nil {
+		return err
+	}
+	args = append(leading, args...)
+
+	name := member.Property.Value
+	if hash, ok := recv.(*object.Hash); ok {
+		key := &object.String{Value: name}
+		if pair, ok := hash.Pairs[key.HashKey()]; ok {
+			return applyFunction(pair.Value, args, named)
+		}
+	}
+
+	builtin, ok := builtins[name]
+	if !ok {
+		return newError("unknown method %s for %s", name, recv.Type())
+	}
+	return applyFunction(builtin, append([]object.Object{recv}, args...), named)
+}
+
+// evalArguments evaluates the positional and then the named arguments of
+// call from left to right. It returns the first error, or return, break or
+// continue, that it runs into.
+func evalArguments(call *ast.CallExpression, env *object.Environment) ([]object.Object, map[string]object.Object, object.Object) {
+	args := evalExpressions(call.Arguments, env)
+	if len(args) == 1 && isAbrupt(args[0]) {
+		return nil, nil, args[0]
+	}
+
+	var named map[string]object.Object
+	for _, arg := range call.NamedArguments {
+		val := Eval(arg.Value, env)
+		if isAbrupt(val) {
+			return nil, nil, val
+		}
+		if named == nil {
+			named = make(map[string]object.Object)
+		}
+		named[arg.Name.Value] = val
+	}
+	return args, named, nil
+}
+
 func evalHashLiteral(hash *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	result := object.NewHash()
 
-	for keyNode, valueNode := range hash.Pairs {
-		key := Eval(keyNode, env)
-		if isError(key) {
+	for _, pair := range hash.Pairs {
+		key := Eval(pair.Key, env)
+		if isAbrupt(key) {
 			return key
 		}
-		value := Eval(valueNode, env)
-		if isError(value) {
+		value := Eval(pair.Value, env)
+		if isAbrupt(value) {
 			return value
 		}
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
 			return newError("hash key must be hashable: %s", key.Type())
 		}
-		pairs[hashKey.HashKey()] = object.HashPair{
+		result.Set(hashKey.HashKey(), object.HashPair{
 			Key:   key,
 			Value: value,
-		}
+		})
 	}
-	return &object.Hash{Pairs: pairs}
+	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// applyFunction calls fn with positional args and the named arguments in
+// named, which may be nil.
+func applyFunction(fn object.Object, args []object.Object, named map[string]object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		extendedEnv, err := extendFunctionEnv(fn, args, named)
+		if err != nil {
+			// a return in a default value returns from fn
+			return unwrapReturnValue(err)
+		}
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
+		for _, name := range sortedNames(named) {
+			if !fn.AcceptsOption(name) {
+				return newError("unknown named argument: %s", name)
+			}
+		}
+		if fn.OptionsFn != nil {
+			return fn.OptionsFn(named, args...)
+		}
 		return fn.Fn(args...)
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv binds the parameters of fn to args and named in a new
+// environment enclosed by the one fn was defined in. Named arguments bind by
+// parameter name and cannot go to the rest parameter. Default values are
+// evaluated at call time in the new environment, so they can refer to
+// earlier parameters. A default that fails or returns stops the call, and
+// its result is returned instead of an environment.
+func extendFunctionEnv(fn *object.Function, args []object.Object, named map[string]object.Object) (*object.Environment, object.Object) {
+	for _, name := range sortedNames(named) {
+		idx := -1
+		for i, param := range fn.Parameters {
+			if param.Value == name {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, newError("unknown named argument: %s", name)
+		}
+		if idx < len(args) {
+			return nil, newError("argument %s given both by position and by name", name)
+		}
+	}
+
+	// With named arguments there cannot be too many positional ones, as each
+	// named one follows them; a missing one is reported by name below.
+	if len(named) == 0 {
+		required := 0
+		for i := range fn.Parameters {
+			if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+				required++
+			}
+		}
+		if err := checkArity(len(args), required, len(fn.Parameters), fn.Rest != nil); err != nil {
+			return nil, err
+		}
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+		if val, ok := named[param.Value]; ok {
+			env.Set(param.Value, val)
+			continue
+		}
+		if paramIdx >= len(fn.Defaults) || fn.Defaults[paramIdx] == nil {
+			return nil, newError("missing argument for parameter %s", param.Value)
+		}
+		val := Eval(fn.Defaults[paramIdx], env)
+		if isAbrupt(val) {
+			return nil, val
+		}
+		env.Set(param.Value, val)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return env, nil
+}
+
+// sortedNames returns the names of named arguments in a fixed order, so that
+// errors about them do not depend on map iteration order.
+func sortedNames(named map[string]object.Object) []string {
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	return env
+// checkArity reports an error unless got arguments are acceptable for a
+// function with min required and max total parameters.
+func checkArity(got, min, max int, variadic bool) *object.Error {
+	switch {
+	case variadic && got < min:
+		return newError("wrong number of arguments. got=%d, want>=%d", got, min)
+	case variadic:
+		return nil
+	case min == max && got != min:
+		return newError("wrong number of arguments. got=%d, want=%d", got, min)
+	case got < min || got > max:
+		return newError("wrong number of arguments. got=%d, want=%d..%d", got, min, max)
+	}
+	return nil
 }
 
 func unwrapReturnValue(result object.Object) object.Object {
@@ -373,9 +1194,24 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
-func isError(obj object.Object) bool {
+// withPos attaches the position of node to obj if it is an error that has
+// not been located yet, so the innermost failing node wins.
+func withPos(obj object.Object, node ast.Node) object.Object {
+	if err, ok := obj.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return obj
+}
+
+// isAbrupt reports whether obj has to be passed up instead of used as a
+// value: an error, or a return, break or continue from a block used as an
+// expression, as in let x = if (done) { break } else { 1 }.
+func isAbrupt(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR_OBJECT
+		switch obj.Type() {
+		case object.ERROR_OBJECT, object.RETURN_OBJECT, object.BREAK_OBJECT, object.CONTINUE_OBJECT:
+			return true
+		}
 	}
 
 	return false
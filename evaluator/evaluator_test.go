@@ -1,6 +1,7 @@
 package evaluator
 
 import (
+	"math"
 	"testing"
 
 	"github.com/wawoon/monkeylang/lexer"
@@ -22,6 +23,21 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{input: "-50 + 100 + -50", expected: 0},
 		{input: "5 * 2 + 10", expected: 20},
 		{input: "5 + 2 * 10", expected: 25},
+		{input: "0xff + 0b1 + 0o10", expected: 264},
+		{input: "1_000 * 1_000", expected: 1000000},
+		{input: "7 % 3", expected: 1},
+		{input: "-7 % 3", expected: -1},
+		{input: "2 ** 10", expected: 1024},
+		{input: "2 ** 3 ** 2", expected: 512},
+		{input: "-2 ** 2", expected: -4},
+		{input: "5 ** 0", expected: 1},
+		{input: "0b1100 & 0b1010", expected: 8},
+		{input: "0b1100 | 0b1010", expected: 14},
+		{input: "0b1100 ^ 0b1010", expected: 6},
+		{input: "~0", expected: -1},
+		{input: "1 << 10", expected: 1024},
+		{input: "-16 >> 2", expected: -4},
+		{input: "0xff & 1 << 4", expected: 16},
 	}
 
 	for _, test := range tests {
@@ -30,6 +46,76 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{input: "1.5", expected: 1.5},
+		{input: "-2.5", expected: -2.5},
+		{input: "1.5 + 2.25", expected: 3.75},
+		{input: "0.1 * 10", expected: 1},
+		{input: "3 / 2.0", expected: 1.5},
+		{input: "1 + 0.5", expected: 1.5},
+		{input: "10 - 2.5 * 2", expected: 5},
+		{input: "1.5e2", expected: 150},
+		{input: "7.5 % 2", expected: 1.5},
+		{input: "2.0 ** 0.5 ** 2", expected: math.Pow(2, 0.25)},
+		{input: "4 ** 0.5", expected: 2},
+		{input: "1.0 / 0", expected: math.Inf(1)},
+		{input: "-1 / 0.0", expected: math.Inf(-1)},
+	}
+
+	for _, test := range tests {
+		evaluated := testEval(test.input)
+		testFloatObject(t, evaluated, test.expected)
+	}
+}
+
+func TestEvalFloatNaN(t *testing.T) {
+	evaluated := testEval("let nan = 0.0 / 0; nan")
+	f, ok := evaluated.(*object.Float)
+	if !ok {
+		t.Fatalf("Expected a float, but got %s", evaluated.Type())
+	}
+	if !math.IsNaN(f.Value) {
+		t.Fatalf("Expected NaN, but got %g", f.Value)
+	}
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{input: "let nan = 0.0 / 0; nan == nan", expected: false},
+		{input: "let nan = 0.0 / 0; nan != nan", expected: true},
+		{input: "let nan = 0.0 / 0; nan < 1", expected: false},
+		{input: "let nan = 0.0 / 0; nan > 1", expected: false},
+		{input: "let nan = 0.0 / 0; nan >= 1", expected: false},
+	}
+	for _, test := range tests {
+		testBooleanObject(t, testEval(test.input), test.expected)
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "1.5", expected: "1.5"},
+		{input: "2.0", expected: "2.0"},
+		{input: "1e21", expected: "1e+21"},
+		{input: "1.0 / 0", expected: "+Inf"},
+		{input: "0.0 / 0", expected: "NaN"},
+	}
+	for _, test := range tests {
+		evaluated := testEval(test.input)
+		if evaluated.Inspect() != test.expected {
+			t.Errorf("Expected %q, but got %q", test.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -57,6 +143,16 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{input: "(1 < 2) == false", expected: false},
 		{input: "(1 > 2) == true", expected: false},
 		{input: "(1 > 2) == false", expected: true},
+		{input: "1.5 < 2", expected: true},
+		{input: "2 < 1.5", expected: false},
+		{input: "1 == 1.0", expected: true},
+		{input: "0.1 + 0.2 == 0.3", expected: false},
+		{input: "1 <= 1", expected: true},
+		{input: "1 >= 2", expected: false},
+		{input: "2 <= 1.5", expected: false},
+		{input: "1.5 >= 1.5", expected: true},
+		{input: "6 & 1 == 0", expected: true},
+		{input: "2.5 != 2.5", expected: false},
 	}
 
 	for _, test := range tests {
@@ -84,6 +180,31 @@ func TestEvalBangOperator(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{input: "true && true", expected: true},
+		{input: "true && false", expected: false},
+		{input: "false || true", expected: true},
+		{input: "false || false", expected: false},
+		{input: "1 < 2 && 2 < 3", expected: true},
+		{input: "1 > 2 || 2 > 3", expected: false},
+		{input: "5 && \"x\"", expected: true},
+		{input: "false && foobar", expected: false},
+		{input: "true || foobar", expected: true},
+		{input: "let n = 0; n != 0 && 10 / n > 1", expected: false},
+	}
+
+	for _, test := range tests {
+		evaluated := testEval(test.input)
+		testBooleanObject(t, evaluated, test.expected)
+	}
+
+	testErrorObject(t, testEval("true && foobar"), "identifier not found: foobar")
+}
+
 func TestIfElseExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -134,6 +255,161 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestWhileLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let i = 0; while (i < 10) { let i = i + 1; }; i`, 10},
+		{`let i = 0; while (false) { let i = i + 1; }; i`, 0},
+		{`while (false) { 1 }`, nil},
+		{`let i = 0; while (true) { let i = i + 1; if (i == 5) { break; } }; i`, 5},
+		{`
+		let i = 0;
+		let sum = 0;
+		while (i < 10) {
+			let i = i + 1;
+			if (i % 2 == 0) { continue; }
+			let sum = sum + i;
+		}
+		sum`, 25},
+		{`
+		let i = 0;
+		let count = 0;
+		while (i < 3) {
+			let i = i + 1;
+			let j = 0;
+			while (true) {
+				let j = j + 1;
+				if (j > 4) { break }
+				if (j % 2 == 0) { continue }
+				let count = count + 1;
+			}
+		}
+		count`, 6},
+		{`
+		let find = fn(limit) {
+			let i = 0;
+			while (true) {
+				if (i * i > limit) { return i; }
+				let i = i + 1;
+			}
+		};
+		find(50)`, 8},
+		{`let i = 0; while (i < 100000) { let i = i + 1; }; i`, 100000},
+		{`
+		let i = 0;
+		let out = [];
+		while (i < 5) {
+			i += 1;
+			out = push(out, 10 * if (i == 3) { break } else { i });
+		}
+		i * 100 + len(out)`, 302},
+		{`
+		let i = 0;
+		let sum = 0;
+		while (i < 5) {
+			i += 1;
+			let x = if (i % 2 == 0) { continue } else { i };
+			sum += x;
+		}
+		sum`, 9},
+		{`let f = fn() { let x = if (true) { return 5 }; 10 }; f()`, 5},
+		{`let f = fn() { [1, match (2) { 2 => { return 7 }, _ => 0 }]; 10 }; f()`, 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+
+	testErrorObject(t, testEval(`let i = 0; while (i < 3) { let i = i + true; }`), "type mismatch: INTEGER + BOOLEAN")
+	testErrorObject(t, testEval(`while (missing) { 1 }`), "identifier not found: missing")
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let first = fn(xs) { for (x in xs) { if (x > 2) { return x } }; -1 }; first([1, 5, 3])`, 5},
+		{`let first = fn(xs) { for (x in xs) { if (x > 10) { return x } }; -1 }; first([1, 5, 3])`, -1},
+		{`let at = fn(xs, n) { for (i, x in xs) { if (i == n) { return x } } }; at([7, 8, 9], 2)`, 9},
+		{`let find = fn(s) { for (i, c in s) { if (c == '語') { return i } } }; find("日本語")`, 2},
+		{`let get = fn(h) { for (k, v in h) { if (k == "b") { return v } } }; get({"a": 1, "b": 2})`, 2},
+		{`let get = fn(h) { for (k in h) { if (k != "a") { return k } } }; get({"a": 1, "b": 2})`, "b"},
+		{`let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { break } ; if (x == 3) { return x } } ; 0 }; f()`, 0},
+		{`let f = fn() { for (x in [1, 2, 3]) { if (x < 3) { continue } ; return x } }; f()`, 3},
+		{`let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return fn() { x } } } }; f()()`, 2},
+		{`for (x in []) { x }`, nil},
+		{`let x = 10; for (x in [1, 2]) { x }; x`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("Expected %q, but got %s", expected, evaluated.Inspect())
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	testErrorObject(t, testEval(`for (x in 5) { x }`), "cannot iterate over INTEGER")
+	testErrorObject(t, testEval(`for (x in if (true) {}) {}`), "cannot iterate over NULL")
+	testErrorObject(t, testEval(`for (x in [1]) { x + true }`), "type mismatch: INTEGER + BOOLEAN")
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`let x = 1; x = 2; x`, 2},
+		{`let x = 1; x = x + 1`, 2},
+		{`let x = 1; let y = 2; x = y = 3; x + y`, 6},
+		{`let x = 10; x += 5; x`, 15},
+		{`let x = 10; x -= 5; x`, 5},
+		{`let x = 10; x *= 5; x`, 50},
+		{`let x = 10; x /= 5; x`, 2},
+		{`let x = 10; x %= 4; x`, 2},
+		{`let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i; }; sum`, 15},
+		{`let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n`, 2},
+		{`let n = 0; let f = fn() { let n = 5; n = 6; n }; f() + n`, 6},
+		{`
+		let counter = fn() {
+			let count = 0;
+			fn() { count += 1 }
+		};
+		let c = counter();
+		c(); c();
+		c()`, 3},
+		{`
+		let fns = [];
+		for (x in [1, 2, 3]) { fns = push(fns, fn() { x }) };
+		fns[0]() * 100 + fns[1]() * 10 + fns[2]()`, 123},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testErrorObject(t, testEval(`y = 1`), "assignment to undefined variable: y")
+	testErrorObject(t, testEval(`y += 1`), "assignment to undefined variable: y")
+	testErrorObject(t, testEval(`let f = fn() { let z = 1 }; f(); z = 2`), "assignment to undefined variable: z")
+	testErrorObject(t, testEval(`let s = "a"; s -= 1`), "type mismatch: STRING - INTEGER")
+}
+
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -177,6 +453,30 @@ func TestErrorHandling(t *testing.T) {
 			input:    "foobar",
 			expected: "identifier not found: foobar",
 		},
+		{
+			input:    "10 / 0",
+			expected: "division by zero",
+		},
+		{
+			input:    "10 % 0",
+			expected: "division by zero",
+		},
+		{
+			input:    "2 ** -1",
+			expected: "negative exponent: 2 ** -1",
+		},
+		{
+			input:    "1 << -1",
+			expected: "negative shift count: -1",
+		},
+		{
+			input:    "1.5 & 1",
+			expected: "unknown operator: FLOAT & INTEGER",
+		},
+		{
+			input:    "~true",
+			expected: "unknown operator: ~BOOLEAN",
+		},
 		{
 			input:    `{"name": "Monkey"}[fn(x){x}]`,
 			expected: "unusable as hash key: FUNCTION",
@@ -206,6 +506,124 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let [a, b] = [1, 2]; a * 10 + b`, 12},
+		{`let [head, second, ...tail] = [1, 2, 3, 4]; head + second + len(tail) * 100 + tail[1]`, 207},
+		{`let [x, ...rest] = [1]; len(rest)`, 0},
+		{`let [a, b = 5] = [1]; a + b`, 6},
+		{`let [a, b = a * 3] = [2]; b`, 6},
+		{`let [a, [b, c]] = [1, [2, 3]]; a + b + c`, 6},
+		{`let i = 0; while (i < 10) { i += 1; let [a = if (i == 3) { break } else { i }] = []; }; i`, 3},
+		{`let n = 0; for (x in [1, 2, 3]) { let {v = if (x == 2) { continue } else { x }} = {}; n += v }; n`, 4},
+		{`let {host, port} = {"host": "db", "port": 5432, "user": "x"}; port`, 5432},
+		{`let {host, port = 80} = {"host": "db"}; port`, 80},
+		{`let {port: p} = {"port": 1}; p`, 1},
+		{`let {db: {port}} = {"db": {"port": 3306}}; port`, 3306},
+		{`let [{n}, {n: m}] = [{"n": 1}, {"n": 2}]; n * 10 + m`, 12},
+		{`let {xs: [first, ...others]} = {"xs": [7, 8, 9]}; first + len(others)`, 9},
+		{`let pair = fn() { [3, 4] }; let [a, b] = pair(); a * b`, 12},
+		{`let [a, b] = [1, 2]`, nil},
+		{`let [a, b] = 5`, "cannot destructure INTEGER with an array pattern"},
+		{`let {a} = [1]`, "cannot destructure ARRAY with a hash pattern"},
+		{`let [a, b, c] = [1, 2]`, "not enough elements to destructure: got 2, want at least 3"},
+		{`let [a] = [1, 2]`, "too many elements to destructure: got 2, want at most 1"},
+		{`let {host} = {"port": 1}`, "key host not found in hash"},
+		{`let [a, [b, c]] = [1, 2]`, "cannot destructure INTEGER with an array pattern"},
+		{`let [a] = if (true) {}`, "cannot destructure NULL with an array pattern"},
+		{`let [a, b = x] = [1]`, "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		default:
+			if _, ok := evaluated.(*object.Array); !ok {
+				t.Errorf("%s: expected the destructured array, got %s", tt.input, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	describe := `let describe = fn(value) {
+		match (value) {
+			0 => "zero",
+			-1 => "minus one",
+			1.5 => "one and a half",
+			true => "yes",
+			'c' => "char c",
+			"hi" => "greeting",
+			[] => "empty",
+			[x] => "one: " + str(x),
+			[0, y] => "zero then " + str(y),
+			[x, y] => "pair " + str(x + y),
+			{"type": "user", "name": n} => "user " + n,
+			{"type": "admin"} => "admin",
+			[head, ...tail] if len(tail) > 2 => "long " + str(len(tail)),
+			_ => "other",
+		}
+	};
+	`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`describe(0)`, "zero"},
+		{`describe(-1)`, "minus one"},
+		{`describe(1.5)`, "one and a half"},
+		{`describe(true)`, "yes"},
+		{`describe(false)`, "other"},
+		{`describe('c')`, "char c"},
+		{`describe("c")`, "other"},
+		{`describe("hi")`, "greeting"},
+		{`describe([])`, "empty"},
+		{`describe([7])`, "one: 7"},
+		{`describe([0, 3])`, "zero then 3"},
+		{`describe([1, 3])`, "pair 4"},
+		{`describe({"type": "user", "name": "ann", "age": 3})`, "user ann"},
+		{`describe({"type": "admin", "name": "root"})`, "admin"},
+		{`describe({"type": "guest"})`, "other"},
+		{`describe([1, 2, 3, 4])`, "long 3"},
+		{`describe([1, 2, 3])`, "other"},
+		{`describe(50)`, "other"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(describe + tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok || str.Value != tt.expected {
+			t.Errorf("%s: expected %q, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	hundreds := `let f = fn(v) { match (v) { n if n > 100 => { let d = n / 100; "hundreds " + str(d) }, _ => "small" } };`
+	if evaluated := testEval(hundreds + `f(250)`); evaluated.Inspect() != "hundreds 2" {
+		t.Errorf("expected hundreds 2, got %s", evaluated.Inspect())
+	}
+	if evaluated := testEval(hundreds + `f(50)`); evaluated.Inspect() != "small" {
+		t.Errorf("expected small, got %s", evaluated.Inspect())
+	}
+
+	testIntegerObject(t, testEval(`let x = 1; match ([5, 6]) { [x, y] => x * y }; x`), 1)
+	testIntegerObject(t, testEval(`match (2) { 2.0 => 1, _ => 0 }`), 1)
+	testIntegerObject(t, testEval(`let f = fn(xs) { match (xs) { [a, b = if (a > 1) { return 99 } else { 0 }] => a + b } }; f([1]) + f([2])`), 100)
+	testIntegerObject(t, testEval(`let f = fn(h) { match (h) { {a = if (true) { return 7 }} => a } }; f({})`), 7)
+	testIntegerObject(t, testEval(`let f = fn(v) { match (v) { [a, b = 10] => a + b } }; f([1])`), 11)
+	testIntegerObject(t, testEval(`let f = fn() { for (x in [1, 2]) { match (x) { 2 => { return x }, _ => 0 } } }; f()`), 2)
+	testNullObject(t, testEval(`match (1) { 1 => {} }`))
+	testErrorObject(t, testEval(`match (1) { 1 => {} } + 1`), "type mismatch: NULL + INTEGER")
+	testErrorObject(t, testEval(`match (3) { 1 => 1, 2 => 2 }`), "no match arm for 3")
+	testErrorObject(t, testEval(`match ([1]) { [a] if a + true => 1 }`), "type mismatch: INTEGER + BOOLEAN")
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := `fn(x) { x + 2; };`
 	evaluated := testEval(input)
@@ -245,6 +663,83 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let f = fn(a, b = 10) { a + b }; f(1)`, 11},
+		{`let f = fn(a, b = 10) { a + b }; f(1, 2)`, 3},
+		{`let f = fn(a, b = a * 2) { a + b }; f(3)`, 9},
+		{`let n = 1; let f = fn(a = n) { a }; let n = 5; f()`, 5},
+		{`let f = fn(first, ...rest) { len(rest) }; f(1)`, 0},
+		{`let f = fn(first, ...rest) { len(rest) }; f(1, 2, 3)`, 2},
+		{`let f = fn(first, ...rest) { rest[1] }; f(1, 2, 3)`, 3},
+		{`let f = fn(a, b = 2, ...c) { a + b + len(c) }; f(1)`, 3},
+		{`let f = fn(a, b = 2, ...c) { a + b + len(c) }; f(1, 1, 1, 1)`, 4},
+		{`let sum = fn(...xs) { let s = 0; for (x in xs) { s += x }; s }; sum(1, 2, 3, 4)`, 10},
+		{`let f = fn(a, b = if (a > 1) { return 100 } else { 1 }) { a + b }; f(5) + f(1)`, 102},
+		{`let f = fn(a, b) { a }; f(1)`, "wrong number of arguments. got=1, want=2"},
+		{`let f = fn(a, b) { a }; f(1, 2, 3)`, "wrong number of arguments. got=3, want=2"},
+		{`let f = fn() { 1 }; f(1)`, "wrong number of arguments. got=1, want=0"},
+		{`let f = fn(a, b = 1) { a }; f()`, "wrong number of arguments. got=0, want=1..2"},
+		{`let f = fn(a, b = 1) { a }; f(1, 2, 3)`, "wrong number of arguments. got=3, want=1..2"},
+		{`let f = fn(a, b, ...c) { a }; f(1)`, "wrong number of arguments. got=1, want>=2"},
+		{`let f = fn(a = x) { a }; f()`, "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let sub = fn(a, b) { a - b }; sub(b: 1, a: 10)`, 9},
+		{`let sub = fn(a, b) { a - b }; sub(10, b: 1)`, 9},
+		{`let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(1, c: 9)`, 129},
+		{`let f = fn(a = 1, b = a + 1) { b }; f(a: 5)`, 6},
+		{`let f = fn(a, ...rest) { a + len(rest) }; f(a: 1)`, 1},
+		{`let obj = {"scale": fn(x, by = 1) { x * by }}; obj.scale(4, by: 3)`, 12},
+		{`str(255, base: 16)`, "ff"},
+		{`str(-5, base: 2)`, "-101"},
+		{`255.str(base: 8)`, "377"},
+		{`let sub = fn(a, b) { a - b }; sub(1, c: 2)`, "unknown named argument: c"},
+		{`let sub = fn(a, b) { a - b }; sub(1, a: 2)`, "argument a given both by position and by name"},
+		{`let sub = fn(a, b) { a - b }; sub(b: 2)`, "missing argument for parameter a"},
+		{`let sub = fn(a, b) { a - b }; sub(1, 2, b: 3)`, "argument b given both by position and by name"},
+		{`let f = fn(a, ...rest) { a }; f(1, rest: 2)`, "unknown named argument: rest"},
+		{`len("abc", sep: 1)`, "unknown named argument: sep"},
+		{`str(1, base: 1)`, "base for `str` must be an integer from 2 to 36, got 1"},
+		{`str("a", base: 16)`, "argument to `str` with base must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("%s: expected %q, got %q", tt.input, expected, str.Value)
+				}
+				continue
+			}
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
 func TestClosure(t *testing.T) {
 	input := `
 	let newAdder = fn(x) {
@@ -282,6 +777,136 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let 挨拶 = "こんにちは"; let 名前 = "世界"; 挨拶 + "、" + 名前;`
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("evaluated value is not a string: %T, %s", evaluated, evaluated.Inspect())
+	}
+	if str.Value != "こんにちは、世界" {
+		t.Fatalf("string has wrong value: %s", str.Value)
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Monkey"; "Hello ${name}!"`, "Hello Monkey!"},
+		{`let count = 2; "you have ${count + 1} items"`, "you have 3 items"},
+		{`"${1.5} ${true} ${[1, 2]} ${if (false) { 1 }}"`, "1.5 true [1, 2] null"},
+		{`let who = "world"; "outer ${"inner ${who}"}"`, "outer inner world"},
+		{`"${"a" + "b"}${"c"}"`, "abc"},
+		{`"${if (true) {}}"`, "null"},
+		{`"\${escaped}"`, "${escaped}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Fatalf("evaluated value is not a string: %T, %s", evaluated, evaluated.Inspect())
+		}
+		if str.Value != tt.expected {
+			t.Errorf("string has wrong value. got=%q, want=%q", str.Value, tt.expected)
+		}
+	}
+
+	testErrorObject(t, testEval(`"value: ${missing}"`), "identifier not found: missing")
+}
+
+func TestMultiLineStrings(t *testing.T) {
+	input := "let table = `users`;\n" +
+		"let query = fn() {\n" +
+		"\t\"\"\"\n" +
+		"\tSELECT name\n" +
+		"\t  FROM \"\"\" + table + \"\"\"\n" +
+		"\t\"\"\"\n" +
+		"};\n" +
+		"query()"
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("evaluated value is not a string: %T, %s", evaluated, evaluated.Inspect())
+	}
+	if str.Value != "SELECT name\n  FROM users" {
+		t.Errorf("string has wrong value: %q", str.Value)
+	}
+}
+
+func TestChars(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`'a'`, 'a'},
+		{`"hello"[1]`, 'e'},
+		{`"日本語"[2]`, '語'},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, nil},
+		{`"日本語"[3]`, nil},
+		{`""[0]`, nil},
+		{`'a' == 'a'`, true},
+		{`'a' != 'b'`, true},
+		{`'a' < 'b'`, true},
+		{`"x"[0] >= 'y'`, false},
+		{`'a' == "a"`, false},
+		{`"ab" + 'c'`, "abc"},
+		{`'a' + 'b'`, "ab"},
+		{`"id: ${'x'}"`, "id: x"},
+		{`int('A')`, 65},
+		{`char(97)`, 'a'},
+		{`char("本")`, '本'},
+		{`char(int('a') + 1)`, 'b'},
+		{`str('z')`, "z"},
+		{`str(42)`, "42"},
+		{`int(2.9)`, 2},
+		{`let h = {'a': 1}; h["a"[0]]`, 1},
+		{`char(-1)`, "invalid code point for `char`: -1"},
+		{`char("ab")`, "argument to `char` must be a single character, got \"ab\""},
+		{`int(1.0 / 0)`, "cannot convert +Inf to an integer"},
+		{`'a' - 'b'`, "unknown operator: CHAR - CHAR"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case rune:
+			ch, ok := evaluated.(*object.Char)
+			if !ok {
+				t.Errorf("%s: evaluated value is not a char: %T, %s", tt.input, evaluated, evaluated.Inspect())
+				continue
+			}
+			if ch.Value != expected {
+				t.Errorf("%s: char has wrong value. got=%q, want=%q", tt.input, ch.Value, expected)
+			}
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("%s: wrong error. got=%q, want=%q", tt.input, errObj.Message, expected)
+				}
+				continue
+			}
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("%s: evaluated value is not a string: %T, %s", tt.input, evaluated, evaluated.Inspect())
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("%s: string has wrong value. got=%q, want=%q", tt.input, str.Value, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -289,6 +914,7 @@ func TestBuiltinFunctions(t *testing.T) {
 	}{
 		{input: `len("")`, expected: 0},
 		{input: `len("hello world")`, expected: 11},
+		{input: `len("日本語")`, expected: 3},
 		{input: `len(1)`, expected: "argument to `len` not supported, got INTEGER"},
 		{input: `len(1, 2)`, expected: "wrong number of arguments. got=2, want=1"},
 	}
@@ -340,6 +966,37 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`let xs = [1, 2, 3]; xs[0] = 10; xs[0] + xs[1]`, 12},
+		{`let xs = [1, 2, 3]; xs[2] += 5; xs[2]`, 8},
+		{`let xs = [1, 2, 3]; xs[1] = 7`, 7},
+		{`let h = {"a": 1}; h["a"] = 5; h["a"]`, 5},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] + h["b"]`, 3},
+		{`let h = {"a": 1}; h["a"] *= 3; h["a"]`, 3},
+		{`let grid = [[1, 2], [3, 4]]; grid[1][0] = 9; grid[1][0]`, 9},
+		{`let a = [1, 2]; let b = a; b[0] = 5; a[0]`, 5},
+		{`let set = fn(xs) { xs[0] = 42 }; let a = [1]; set(a); a[0]`, 42},
+		{`let a = [1]; let b = push(a, 2); b[0] = 7; a[0]`, 1},
+		{`let counts = {'a': 0, 'b': 0, 'c': 0}; for (c in "abca") { counts[c] += 1 }; counts['a']`, 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testErrorObject(t, testEval(`let xs = [1, 2]; xs[2] = 0`), "index out of range: 2 with length 2")
+	testErrorObject(t, testEval(`let xs = [1, 2]; xs[-1] = 0`), "index out of range: -1 with length 2")
+	testErrorObject(t, testEval(`let xs = [1, 2]; xs["a"] = 0`), "array index must be INTEGER, got STRING")
+	testErrorObject(t, testEval(`let h = {}; h[[1]] = 0`), "unusable as hash key: ARRAY")
+	testErrorObject(t, testEval(`let h = {}; h[fn() {}] = 0`), "unusable as hash key: FUNCTION")
+	testErrorObject(t, testEval(`let s = "abc"; s[0] = 'x'`), "index assignment not supported: STRING")
+	testErrorObject(t, testEval(`let h = {}; h["a"] += 1`), "type mismatch: NULL + INTEGER")
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -376,6 +1033,161 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestHashOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 3, true: 4, 'c': 5}`, `{b: 1, a: 2, 3: 3, true: 4, c: 5}`},
+		{`let h = {"z": 1, "y": 2}; h["x"] = 3; h["z"] = 4; h`, `{z: 4, y: 2, x: 3}`},
+		{`{"a": 1, "b": 2, "a": 3}`, `{a: 3, b: 2}`},
+		{`let n = 1; {"a": n *= 2, "b": n += 1, "c": n *= 10}`, `{a: 2, b: 3, c: 30}`},
+		{`let s = ""; for (k, v in {"q": 1, "w": 2, "e": 3}) { s += k }; s`, `qwe`},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 10; i++ {
+			evaluated := testEval(tt.input)
+			if evaluated.Inspect() != tt.expected {
+				t.Fatalf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestArrowFunctions(t *testing.T) {
+	mapFn := `let map = fn(xs, f) { let out = []; for (x in xs) { out = push(out, f(x)) }; out };`
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let add = (a, b) => a + b; add(2, 3)`, 5},
+		{`let double = x => x * 2; double(21)`, 42},
+		{`(() => 7)()`, 7},
+		{`let f = (a, b = 10) => a + b; f(1) + f(1, b: 1)`, 13},
+		{`let f = (...xs) => len(xs); f(1, 2, 3)`, 3},
+		{`let f = x => { let y = x + 1; y * 2 }; f(2)`, 6},
+		{`let adder = x => y => x + y; adder(2)(3)`, 5},
+		{`let f = x => { for (i in [1, 2, 3]) { if (i == x) { return i * 10 } }; 0 }; f(2)`, 20},
+		{mapFn + `map([1, 2, 3], x => x * x)`, []int64{1, 4, 9}},
+		{mapFn + `[1, 2] |> map(x => x + 1)`, []int64{2, 3}},
+		{`let x = 3; (x) * 2`, 6},
+		{`let f = () => {}; f()`, nil},
+		{`(x => {})(1)`, nil},
+		{`fn() {}()`, nil},
+		{`let f = fn() { if (true) {} }; f()`, nil},
+		{`let f = (a, b) => a; f(1)`, "wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int64:
+			arr, ok := evaluated.(*object.Array)
+			if !ok || len(arr.Elements) != len(expected) {
+				t.Errorf("%s: expected %v, got %s", tt.input, expected, evaluated.Inspect())
+				continue
+			}
+			for i, e := range expected {
+				testIntegerObject(t, arr.Elements[i], e)
+			}
+		case string:
+			testErrorObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`[1, 2] |> push(3) |> len`, 3},
+		{`let double = fn(x) { x * 2 }; 5 |> double`, 10},
+		{`let sub = fn(a, b) { a - b }; 10 |> sub(3)`, 7},
+		{`let add = fn(a, b) { a + b }; 1 + 2 |> add(3)`, 6},
+		{`let f = fn(a, b = 1, c = 2) { a * 100 + b * 10 + c }; 5 |> f(c: 7)`, 517},
+		{`let fs = [fn(x) { x + 1 }]; 1 |> fs[0]`, 2},
+		{`[1, 2, 3] |> fn(xs) { first(xs) + last(xs) }`, 4},
+		{`let h = {"inc": fn(x, n) { x + n }}; 1 |> h.inc(2)`, 3},
+		{`let xs = [1]; 2 |> xs.push() |> len`, 2},
+		{`255 |> str(base: 16)`, "ff"},
+		{`5 |> 1`, "not a function: INTEGER"},
+		{`1 |> undefined`, "identifier not found: undefined"},
+		{`let f = fn(a) { a }; 1 |> f(2)`, "wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("%s: expected error %q, got %q", tt.input, expected, errObj.Message)
+				}
+				continue
+			}
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%s: expected %q, got %s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestMemberAccess(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let config = {"name": "db", "port": 5432}; config.name`, "db"},
+		{`let config = {"db": {"port": 5432}}; config.db.port`, 5432},
+		{`{"a": 1}.b`, nil},
+		{`[1, 2, 3].len()`, 3},
+		{`"日本語".len()`, 3},
+		{`let xs = [1, 2]; xs.push(3).len()`, 3},
+		{`[1, 2, 3].rest().first()`, 2},
+		{`let xs = [1]; let ys = xs.push(2); xs.len() * 10 + ys.last()`, 12},
+		{`let obj = {"double": fn(x) { x * 2 }}; obj.double(21)`, 42},
+		{`{"a": 1, "b": 2}.len()`, "argument to `len` not supported, got HASH"},
+		{`[1].nope()`, "unknown method nope for ARRAY"},
+		{`[1].first`, "ARRAY has no field first"},
+		{`let s = 5; s.x`, "INTEGER has no field x"},
+		{`let h = {"a": 1}; h.a = 2; h.a`, 2},
+		{`let h = {"a": 1}; h.b = 5; h["b"] + h.a`, 6},
+		{`let h = {"count": 1}; h.count += 1; h.count *= 10; h.count`, 20},
+		{`let h = {"db": {"port": 1}}; let db = h.db; h.db.port = 5432; db.port`, 5432},
+		{`let h = {}; h.a = h.b = 3; h.a + h.b`, 6},
+		{`let xs = [1]; xs.first = 2`, "ARRAY has no field first"},
+		{`let h = {}; h.count += 1`, "type mismatch: NULL + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("%s: expected %q, got %q", tt.input, expected, obj.Value)
+				}
+			default:
+				testErrorObject(t, evaluated, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -424,6 +1236,17 @@ func testIntegerObject(t *testing.T, evaluated object.Object, expected int64) {
 	}
 }
 
+func testFloatObject(t *testing.T, evaluated object.Object, expected float64) {
+	if evaluated.Type() != object.FLOAT_OBJECT {
+		t.Errorf("Expected a float, but got %s", evaluated.Type())
+		return
+	}
+
+	if evaluated.(*object.Float).Value != expected {
+		t.Errorf("Expected %g, but got %g", expected, evaluated.(*object.Float).Value)
+	}
+}
+
 func testBooleanObject(t *testing.T, evaluated object.Object, expected bool) {
 	if evaluated.Type() != object.BOOLEAN_OBJECT {
 		t.Errorf("Expected a boolean, but got %s", evaluated.Type())
@@ -449,3 +1272,30 @@ func testErrorObject(t *testing.T, obj object.Object, expected string) {
 		t.Errorf("Expected %s, but got %s", expected, obj.(*object.Error).Message)
 	}
 }
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    "let a = 5;\nlet b = a + true;",
+			expected: "Error: 2:11: type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			input:    "let f = fn() {\n  foobar\n};\nf();",
+			expected: "Error: 2:3: identifier not found: foobar",
+		},
+		{
+			input:    "len(1,\n 2)",
+			expected: "Error: 1:4: wrong number of arguments. got=2, want=1",
+		},
+	}
+
+	for _, test := range tests {
+		evaluated := testEval(test.input)
+		if evaluated.Inspect() != test.expected {
+			t.Errorf("Expected %q, but got %q", test.expected, evaluated.Inspect())
+		}
+	}
+}
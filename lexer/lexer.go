@@ -1,33 +1,175 @@
 package lexer
 
-import "github.com/wawoon/monkeylang/token"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/wawoon/monkeylang/token"
+)
+
+// readChunkSize is the number of bytes a reader-backed lexer requests at a
+// time.
+const readChunkSize = 4096
 
 type Lexer struct {
 	input        string
+	filename     string
 	position     int
 	readPosition int
-	ch           byte
+	ch           rune
+
+	// src is the reader the input is pulled from, nil once it has been
+	// exhausted or for a lexer created with New. offset is the absolute
+	// offset of input[0] since consumed input is discarded as lexing goes.
+	src    io.Reader
+	chunk  []byte
+	offset int
+	err    error
+
+	errReported bool
+
+	// line and column of l.ch, the column counts runes rather than bytes
+	line   int
+	column int
+
+	// comments holds the comments skipped so far, unless dropComments is set
+	comments     []token.Token
+	dropComments bool
+
+	// interpolations has one entry per string interpolation `${...}` being
+	// lexed, counting the braces opened inside it so that the matching `}`
+	// can resume the string.
+	interpolations []int
 }
 
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+// New returns a lexer for input. An optional filename is recorded in the
+// position of every token.
+func New(input string, filename ...string) *Lexer {
+	l := &Lexer{input: input, line: 1}
+	if len(filename) > 0 {
+		l.filename = filename[0]
+	}
 	l.readChar()
 	return l
 }
 
+// NewReader returns a lexer that reads its input from r. Only the current
+// token and one chunk of lookahead are buffered, besides the comments kept
+// for Comments. With DropComments, arbitrarily large input can be tokenized
+// in bounded memory.
+func NewReader(r io.Reader, filename ...string) *Lexer {
+	l := &Lexer{src: r, chunk: make([]byte, readChunkSize), line: 1}
+	if len(filename) > 0 {
+		l.filename = filename[0]
+	}
+	l.readChar()
+	return l
+}
+
+// Err returns the first error, other than io.EOF, returned by the reader.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// Tokens returns every remaining token, ending with the EOF token.
+func (l *Lexer) Tokens() []token.Token {
+	var tokens []token.Token
+	l.Each(func(tok token.Token) bool {
+		tokens = append(tokens, tok)
+		return true
+	})
+	return tokens
+}
+
+// Each calls fn for every remaining token up to and including EOF, and stops
+// early if fn returns false. Tokens are produced on demand, so together with
+// NewReader it streams the input without holding it in memory.
+func (l *Lexer) Each(fn func(token.Token) bool) {
+	for {
+		tok := l.NextToken()
+		if !fn(tok) || tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+// fill reads from the source until a complete rune is buffered at index i or
+// the source is exhausted.
+func (l *Lexer) fill(i int) {
+	for l.src != nil && (i >= len(l.input) || !utf8.FullRuneInString(l.input[i:])) {
+		n, err := l.src.Read(l.chunk)
+		l.input += string(l.chunk[:n])
+		if err != nil {
+			if err != io.EOF {
+				l.err = err
+			}
+			l.src = nil
+		}
+	}
+}
+
+// discard drops the input before the current character so that the buffer
+// of a reader-backed lexer does not grow with the size of the source.
+func (l *Lexer) discard() {
+	n := l.position
+	if n > len(l.input) {
+		n = len(l.input)
+	}
+	l.offset += n
+	l.position -= n
+	l.readPosition -= n
+	l.input = l.input[n:]
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.fill(l.readPosition)
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition++
+	l.readPosition += width
+	l.column++
+}
+
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.offset + l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
 }
 
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 	l.skipWhitespace()
+	for l.ch == '/' && (l.PeekChar() == '/' || l.PeekChar() == '*') {
+		pos := l.pos()
+		start := l.position
+		text, err := l.readComment()
+		if err != nil {
+			tok := l.illegal(start, l.position, err.Error())
+			tok.Pos = pos
+			return tok
+		}
+		if !l.dropComments {
+			l.comments = append(l.comments, token.Token{Type: token.COMMENT, Literal: text, Pos: pos})
+		}
+		l.skipWhitespace()
+	}
+	l.discard()
+	pos := l.pos()
 
 	switch l.ch {
 	case '=':
@@ -37,6 +179,8 @@ func (l *Lexer) NextToken() token.Token {
 				Literal: string(l.ch) + string(l.PeekChar()),
 			}
 			l.readChar()
+		} else if l.PeekChar() == '>' {
+			tok = l.newTwoCharToken(token.ARROW)
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -44,12 +188,32 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.PeekChar() == '.' {
+			l.readChar()
+			if l.PeekChar() == '.' {
+				l.readChar()
+				tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+			} else {
+				tok = token.Token{Type: token.ILLEGAL, Literal: "..", Message: `unexpected ".."`}
+			}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.PeekChar() == '=' {
+			tok = l.newTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.PeekChar() == '=' {
+			tok = l.newTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.PeekChar() == '=' {
 			tok = token.Token{
@@ -61,9 +225,29 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.PeekChar() == '*' {
+			tok = l.newTwoCharToken(token.POWER)
+		} else if l.PeekChar() == '=' {
+			tok = l.newTwoCharToken(token.ASTERISK_ASSIGN)
+		} else {
+			tok = newToken(token.ASTERISK, l.ch)
+		}
+	case '%':
+		if l.PeekChar() == '=' {
+			tok = l.newTwoCharToken(token.MODULO_ASSIGN)
+		} else {
+			tok = newToken(token.MODULO, l.ch)
+		}
+	case '^':
+		tok = newToken(token.BIT_XOR, l.ch)
+	case '~':
+		tok = newToken(token.BIT_NOT, l.ch)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.PeekChar() == '=' {
+			tok = l.newTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -73,77 +257,545 @@ func (l *Lexer) NextToken() token.Token {
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		switch l.PeekChar() {
+		case '=':
+			tok = l.newTwoCharToken(token.LTE)
+		case '<':
+			tok = l.newTwoCharToken(token.SHIFT_LEFT)
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		switch l.PeekChar() {
+		case '=':
+			tok = l.newTwoCharToken(token.GTE)
+		case '>':
+			tok = l.newTwoCharToken(token.SHIFT_RIGHT)
+		default:
+			tok = newToken(token.GT, l.ch)
+		}
 	case '&':
-		tok = newToken(token.AND, l.ch)
+		if l.PeekChar() == '&' {
+			tok = l.newTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.BIT_AND, l.ch)
+		}
 	case '|':
-		tok = newToken(token.OR, l.ch)
+		if l.PeekChar() == '|' {
+			tok = l.newTwoCharToken(token.OR)
+		} else if l.PeekChar() == '>' {
+			tok = l.newTwoCharToken(token.PIPE)
+		} else {
+			tok = newToken(token.BIT_OR, l.ch)
+		}
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		n := len(l.interpolations)
+		if n > 0 && l.interpolations[n-1] == 0 {
+			l.interpolations = l.interpolations[:n-1]
+			tok = l.readStringToken(token.INTERP_MID, token.INTERP_END)
+			break
+		}
+		if n > 0 {
+			l.interpolations[n-1]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case '"':
-		tok = token.Token{Type: token.STRING, Literal: l.readString()}
+		if l.fill(l.position + 2); strings.HasPrefix(l.input[l.position:], `"""`) {
+			tok = l.readTextBlock()
+			break
+		}
+		tok = l.readStringToken(token.INTERP_START, token.STRING)
+	case '`':
+		tok = l.readRawString()
+	case '\'':
+		tok = l.readCharLiteral()
 	case 0:
+		if l.err != nil && !l.errReported {
+			// surface a failed read once so the input is not mistaken for a
+			// complete program
+			l.errReported = true
+			tok = token.Token{Type: token.ILLEGAL, Message: "read error: " + l.err.Error()}
+			break
+		}
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok = l.readNumber()
+			tok.Pos = pos
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			// keep the raw bytes so that invalid UTF-8 is reported as written
+			text := l.input[l.position:l.readPosition]
+			tok = token.Token{Type: token.ILLEGAL, Literal: text, Message: fmt.Sprintf("unexpected character %q", text)}
 		}
 	}
 
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
-func newToken(t token.TokenType, ch byte) token.Token {
+// illegal returns an ILLEGAL token for the source text input[start:end],
+// with msg saying what is wrong with it.
+func (l *Lexer) illegal(start, end int, msg string) token.Token {
+	if end > len(l.input) {
+		end = len(l.input)
+	}
+	return token.Token{Type: token.ILLEGAL, Literal: l.input[start:end], Message: msg}
+}
+
+func newToken(t token.TokenType, ch rune) token.Token {
 	return token.Token{Type: t, Literal: string(ch)}
 }
 
-func isLetter(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+// newTwoCharToken consumes the next character and returns a token made of it
+// and the current one.
+func (l *Lexer) newTwoCharToken(t token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: t, Literal: string(ch) + string(l.ch)}
+}
+
+// Identifiers follow the same rule as Go: they start with a Unicode letter
+// or '_', followed by any number of Unicode letters, '_' or Unicode digits.
+// Number literals only ever use the ASCII digits 0-9.
+func isLetter(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch)
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
 
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
-	for {
+// readStringToken reads the string segment following the current character,
+// which is either the opening quote or the `}` closing an interpolation. A
+// segment ending in `${` becomes an interp token and starts a new
+// interpolation, one ending in the closing quote becomes an end token.
+func (l *Lexer) readStringToken(interp, end token.TokenType) token.Token {
+	start := l.position
+	str, interpolated, err := l.readString()
+	if interpolated {
+		l.interpolations = append(l.interpolations, 0)
+	}
+	if err != nil {
+		return l.illegal(start, l.readPosition, err.Error())
+	}
+	if interpolated {
+		return token.Token{Type: interp, Literal: str}
+	}
+	return token.Token{Type: end, Literal: str}
+}
+
+// readCharLiteral reads a character literal such as 'a', '日' or '\n'. It
+// accepts the same escapes as string literals, with \' for a single quote.
+func (l *Lexer) readCharLiteral() token.Token {
+	start := l.position
+	l.readChar()
+	var ch rune
+	switch l.ch {
+	case '\'':
+		return l.illegal(start, l.readPosition, "empty character literal")
+	case 0, '\n':
+		return l.illegal(start, l.position, "unterminated character literal")
+	case '\\':
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == '\'' {
+			ch = '\''
 			break
 		}
+		escaped, err := l.readEscape()
+		if err != nil {
+			l.skipCharLiteral()
+			return l.illegal(start, l.readPosition, err.Error())
+		}
+		ch = escaped
+	default:
+		ch = l.ch
 	}
-	return l.input[position:l.position]
+
+	if l.PeekChar() != '\'' {
+		if l.skipCharLiteral() {
+			return l.illegal(start, l.readPosition, "character literal must contain exactly one character")
+		}
+		return l.illegal(start, l.readPosition, "unterminated character literal")
+	}
+	l.readChar()
+	return token.Token{Type: token.CHAR, Literal: string(ch)}
+}
+
+// skipCharLiteral skips the rest of a malformed character literal up to its
+// closing quote on the same line, and reports whether there was one.
+func (l *Lexer) skipCharLiteral() bool {
+	for {
+		switch l.PeekChar() {
+		case '\'':
+			l.readChar()
+			return true
+		case 0, '\n':
+			return false
+		}
+		l.readChar()
+	}
+}
+
+// readRawString reads a `raw string`. It may span several lines and its
+// content is taken as written: there are no escapes or interpolations.
+// Carriage returns are discarded so that the value does not depend on the
+// line endings of the source file.
+func (l *Lexer) readRawString() token.Token {
+	start := l.position
+	var out strings.Builder
+	for {
+		l.readChar()
+		switch l.ch {
+		case '`':
+			return token.Token{Type: token.STRING, Literal: out.String()}
+		case 0:
+			return l.illegal(start, l.position, "unterminated raw string literal")
+		case '\r':
+		default:
+			out.WriteString(l.input[l.position:l.readPosition])
+		}
+	}
+}
+
+// readTextBlock reads a triple-quoted """text block""". Like a raw string it
+// has no escapes or interpolations. In addition, a line break right after
+// the opening quotes and a last line holding only the indentation of the
+// closing quotes are dropped, and the indentation common to all non-blank
+// lines is removed, so a block can be indented along with the code around it.
+func (l *Lexer) readTextBlock() token.Token {
+	start := l.position
+	l.readChar()
+	l.readChar()
+
+	var out strings.Builder
+	for {
+		l.readChar()
+		if l.ch == 0 {
+			return l.illegal(start, l.position, "unterminated text block")
+		}
+		if l.fill(l.position + 2); strings.HasPrefix(l.input[l.position:], `"""`) {
+			l.readChar()
+			l.readChar()
+			return token.Token{Type: token.STRING, Literal: dedent(out.String())}
+		}
+		if l.ch != '\r' {
+			out.WriteString(l.input[l.position:l.readPosition])
+		}
+	}
+}
+
+func dedent(text string) string {
+	text = strings.TrimPrefix(text, "\n")
+	lines := strings.Split(text, "\n")
+	if last := lines[len(lines)-1]; isBlank(last) {
+		lines = lines[:len(lines)-1]
+	}
+
+	indent := ""
+	first := true
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+		lead := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent = lead
+			first = false
+			continue
+		}
+		for !strings.HasPrefix(lead, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+
+	for i, line := range lines {
+		if isBlank(line) {
+			lines[i] = ""
+			continue
+		}
+		lines[i] = line[len(indent):]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isBlank(line string) bool {
+	return strings.TrimLeft(line, " \t") == ""
 }
 
-func (l *Lexer) readNumber() string {
+// readString reads a double-quoted string literal up to its closing quote or
+// up to the `${` starting an interpolation, and returns its value with escape
+// sequences resolved. A malformed literal is still read up to its end so that
+// lexing can continue after it.
+func (l *Lexer) readString() (string, bool, error) {
+	var out strings.Builder
+	var err error
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			return out.String(), false, err
+		case 0:
+			return "", false, errors.New("unterminated string literal")
+		case '$':
+			if l.PeekChar() == '{' {
+				l.readChar()
+				return out.String(), true, err
+			}
+			out.WriteRune(l.ch)
+		case '\\':
+			l.readChar()
+			ch, escErr := l.readEscape()
+			if escErr != nil {
+				if err == nil {
+					err = escErr
+				}
+				continue
+			}
+			out.WriteRune(ch)
+		default:
+			out.WriteString(l.input[l.position:l.readPosition])
+		}
+	}
+}
+
+// readEscape decodes the escape sequence whose first character after the
+// backslash is l.ch. Supported escapes are \n, \t, \r, \\, \", \$, \xNN
+// for ASCII characters and \u{N...} for any Unicode code point.
+func (l *Lexer) readEscape() (rune, error) {
+	switch l.ch {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case '\\':
+		return '\\', nil
+	case '"':
+		return '"', nil
+	case '$':
+		return '$', nil
+	case 'x':
+		var value rune
+		for i := 0; i < 2; i++ {
+			d, ok := hexValue(l.PeekChar())
+			if !ok {
+				return 0, errors.New(`invalid escape sequence: \x must be followed by two hex digits`)
+			}
+			l.readChar()
+			value = value*16 + d
+		}
+		if value > unicode.MaxASCII {
+			return 0, fmt.Errorf(`invalid escape sequence: \x%02x is not ASCII, use \u{%x}`, value, value)
+		}
+		return value, nil
+	case 'u':
+		if l.PeekChar() != '{' {
+			return 0, errors.New(`invalid escape sequence: \u must be followed by {`)
+		}
+		l.readChar()
+		var value rune
+		digits := 0
+		for {
+			if l.PeekChar() == '}' {
+				l.readChar()
+				break
+			}
+			d, ok := hexValue(l.PeekChar())
+			if !ok || digits == 6 {
+				return 0, errors.New(`invalid escape sequence: \u{...} must contain 1 to 6 hex digits`)
+			}
+			l.readChar()
+			value = value*16 + d
+			digits++
+		}
+		if digits == 0 {
+			return 0, errors.New(`invalid escape sequence: \u{...} must contain 1 to 6 hex digits`)
+		}
+		if !utf8.ValidRune(value) {
+			return 0, fmt.Errorf(`invalid escape sequence: \u{%x} is not a valid code point`, value)
+		}
+		return value, nil
+	case 0:
+		return 0, errors.New("unterminated string literal")
+	default:
+		return 0, fmt.Errorf(`invalid escape sequence: \%c`, l.ch)
+	}
+}
+
+func hexValue(ch rune) (rune, bool) {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return ch - '0', true
+	case 'a' <= ch && ch <= 'f':
+		return ch - 'a' + 10, true
+	case 'A' <= ch && ch <= 'F':
+		return ch - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// readNumber reads an INT such as 42 or a FLOAT such as 1.5, 1e9 or 2.5E-3.
+// A fraction needs digits on both sides of the dot. Integers may also be
+// written in hex, octal or binary with a 0x, 0o or 0b prefix, and any run of
+// digits may be split by single underscores, as in 1_000_000.
+func (l *Lexer) readNumber() token.Token {
 	position := l.position
-	for isDigit(l.ch) {
+	if l.ch == '0' && numberBase(l.PeekChar()) != 0 {
+		return l.readPrefixedInteger()
+	}
+
+	tokenType := token.INT
+	ok := l.readDigits()
+
+	if l.ch == '.' && isDigit(l.PeekChar()) {
+		tokenType = token.FLOAT
 		l.readChar()
+		ok = l.readDigits() && ok
 	}
-	return l.input[position:l.position]
+
+	if l.ch == 'e' || l.ch == 'E' {
+		tokenType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		if !isDigit(l.ch) {
+			return l.illegal(position, l.position, "malformed float literal: missing exponent digits in "+l.input[position:l.position])
+		}
+		ok = l.readDigits() && ok
+	}
+
+	if !ok {
+		return l.illegal(position, l.position, "malformed number literal: '_' must separate digits in "+l.input[position:l.position])
+	}
+	return token.Token{Type: tokenType, Literal: l.input[position:l.position]}
+}
+
+// readDigits reads decimal digits and underscores, and reports whether every
+// underscore was followed by a digit.
+func (l *Lexer) readDigits() bool {
+	ok := true
+	for isDigit(l.ch) || l.ch == '_' {
+		if l.ch == '_' && !isDigit(l.PeekChar()) {
+			ok = false
+		}
+		l.readChar()
+	}
+	return ok
+}
+
+func (l *Lexer) readPrefixedInteger() token.Token {
+	position := l.position
+	l.readChar()
+	base := numberBase(l.ch)
+	l.readChar()
+
+	isBaseDigit := isDigit
+	if base == 16 {
+		isBaseDigit = isHexDigit
+	}
+
+	var msg string
+	digits := 0
+	for isBaseDigit(l.ch) || l.ch == '_' {
+		if l.ch == '_' {
+			if !isBaseDigit(l.PeekChar()) && msg == "" {
+				msg = "'_' must separate digits in"
+			}
+		} else {
+			if d, _ := hexValue(l.ch); int(d) >= base && msg == "" {
+				msg = fmt.Sprintf("invalid digit %q in", l.ch)
+			}
+			digits++
+		}
+		l.readChar()
+	}
+
+	literal := l.input[position:l.position]
+	if digits == 0 && msg == "" {
+		msg = "no digits in"
+	}
+	if msg != "" {
+		return l.illegal(position, l.position, "malformed number literal: "+msg+" "+literal)
+	}
+	return token.Token{Type: token.INT, Literal: literal}
+}
+
+// numberBase returns the base selected by the character following a leading
+// zero, or 0 if ch is not a base prefix.
+func numberBase(ch rune) int {
+	switch ch {
+	case 'x', 'X':
+		return 16
+	case 'o', 'O':
+		return 8
+	case 'b', 'B':
+		return 2
+	}
+	return 0
+}
+
+func isHexDigit(ch rune) bool {
+	_, ok := hexValue(ch)
+	return ok
+}
+
+// Comments returns the comments skipped so far, in source order. Each one is
+// a COMMENT token whose literal includes the comment delimiters.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// DropComments makes the lexer skip comments without keeping them, so that
+// Comments returns none.
+func (l *Lexer) DropComments() {
+	l.dropComments = true
+}
+
+// readComment reads a `// line comment` up to the end of the line or a
+// `/* block comment */`. Block comments do not nest.
+func (l *Lexer) readComment() (string, error) {
+	position := l.position
+	if l.PeekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return strings.TrimRight(l.input[position:l.position], "\r"), nil
+	}
+
+	l.readChar()
+	l.readChar()
+	for !(l.ch == '*' && l.PeekChar() == '/') {
+		if l.ch == 0 {
+			return "", errors.New("unterminated block comment")
+		}
+		l.readChar()
+	}
+	l.readChar()
+	l.readChar()
+	return l.input[position:l.position], nil
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -152,9 +804,11 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func (l *Lexer) PeekChar() byte {
+func (l *Lexer) PeekChar() rune {
+	l.fill(l.readPosition)
 	if l.readPosition >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPosition]
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return ch
 }
@@ -1,8 +1,11 @@
 package lexer
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/wawoon/monkeylang/token"
 )
@@ -16,7 +19,7 @@ func TestNextToken(t *testing.T) {
 	};
 
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -27,6 +30,7 @@ func TestNextToken(t *testing.T) {
 
 	10 == 10;
 	10 != 9;
+	a && b || c;
 	"foobar";
 	"foo bar";
 	[1, 2];
@@ -110,6 +114,12 @@ func TestNextToken(t *testing.T) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.SEMICOLON, ";"},
 		{token.STRING, "foobar"},
 		{token.SEMICOLON, ";"},
 		{token.STRING, "foo bar"},
@@ -141,3 +151,613 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x + 10;\n"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+	}{
+		{token.LET, token.Position{Filename: "main.mk", Offset: 0, Line: 1, Column: 1}},
+		{token.IDENT, token.Position{Filename: "main.mk", Offset: 4, Line: 1, Column: 5}},
+		{token.ASSIGN, token.Position{Filename: "main.mk", Offset: 6, Line: 1, Column: 7}},
+		{token.INT, token.Position{Filename: "main.mk", Offset: 8, Line: 1, Column: 9}},
+		{token.SEMICOLON, token.Position{Filename: "main.mk", Offset: 9, Line: 1, Column: 10}},
+		{token.IDENT, token.Position{Filename: "main.mk", Offset: 13, Line: 2, Column: 3}},
+		{token.PLUS, token.Position{Filename: "main.mk", Offset: 15, Line: 2, Column: 5}},
+		{token.INT, token.Position{Filename: "main.mk", Offset: 17, Line: 2, Column: 7}},
+		{token.SEMICOLON, token.Position{Filename: "main.mk", Offset: 19, Line: 2, Column: 9}},
+		{token.EOF, token.Position{Filename: "main.mk", Offset: 21, Line: 3, Column: 1}},
+	}
+
+	l := New(input, "main.mk")
+	for _, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("Expected %s, got %s", test.expectedType, tok.Type)
+		}
+		if tok.Pos != test.expectedPos {
+			t.Errorf("Expected %s to be at %+v, got %+v", tok.Type, test.expectedPos, tok.Pos)
+		}
+	}
+}
+
+func TestUnicodeInput(t *testing.T) {
+	input := "let 名前 = \"こんにちは, 世界\";\nλ_1 + x2;\n\xff"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "名前", 1, 5},
+		{token.ASSIGN, "=", 1, 8},
+		{token.STRING, "こんにちは, 世界", 1, 10},
+		{token.SEMICOLON, ";", 1, 21},
+		{token.IDENT, "λ_1", 2, 1},
+		{token.PLUS, "+", 2, 5},
+		{token.IDENT, "x2", 2, 7},
+		{token.SEMICOLON, ";", 2, 9},
+		{token.ILLEGAL, "\xff", 3, 1},
+		{token.EOF, "", 3, 2},
+	}
+
+	l := New(input)
+	for _, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("Expected %s, got %s", test.expectedType, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("Expected %q, got %q", test.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.Line != test.expectedLine || tok.Pos.Column != test.expectedColumn {
+			t.Errorf("Expected %q to be at %d:%d, got %s", tok.Literal, test.expectedLine, test.expectedColumn, tok.Pos)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string // the message of an ILLEGAL token
+	}{
+		{`"a\nb"`, token.STRING, "a\nb"},
+		{`"\tindented\r\n"`, token.STRING, "\tindented\r\n"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"C:\\path"`, token.STRING, `C:\path`},
+		{`"\x41\x7e"`, token.STRING, "A~"},
+		{`"\u{48}\u{65e5}\u{1F600}"`, token.STRING, "H日😀"},
+		{`"unterminated`, token.ILLEGAL, "unterminated string literal"},
+		{`"ends with \"`, token.ILLEGAL, "unterminated string literal"},
+		{`"\q"`, token.ILLEGAL, `invalid escape sequence: \q`},
+		{`"\x4"`, token.ILLEGAL, `invalid escape sequence: \x must be followed by two hex digits`},
+		{`"\xff"`, token.ILLEGAL, `invalid escape sequence: \xff is not ASCII, use \u{ff}`},
+		{`"\u41"`, token.ILLEGAL, `invalid escape sequence: \u must be followed by {`},
+		{`"\u{}"`, token.ILLEGAL, `invalid escape sequence: \u{...} must contain 1 to 6 hex digits`},
+		{`"\u{110000}"`, token.ILLEGAL, `invalid escape sequence: \u{110000} is not a valid code point`},
+	}
+
+	for _, test := range tests {
+		l := New(test.input)
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("%s: Expected %s, got %s", test.input, test.expectedType, tok.Type)
+		}
+		if test.expectedType == token.ILLEGAL {
+			testIllegal(t, tok, test.input, test.expectedLiteral)
+		} else if tok.Literal != test.expectedLiteral {
+			t.Errorf("%s: Expected %q, got %q", test.input, test.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIllegalTokens(t *testing.T) {
+	tests := []struct {
+		input   string
+		literal string
+		message string
+	}{
+		{"@", "@", `unexpected character "@"`},
+		{"\xff", "\xff", `unexpected character "\xff"`},
+		{"a..b", "..", `unexpected ".."`},
+		{`x = "abc`, `"abc`, "unterminated string literal"},
+		{`"a${x}\q" + 1`, `}\q"`, `invalid escape sequence: \q`},
+		{"'ab' + 1", "'ab'", "character literal must contain exactly one character"},
+		{"1 + 0b12 + 3", "0b12", "malformed number literal: invalid digit '2' in 0b12"},
+	}
+
+	for _, test := range tests {
+		l := New(test.input)
+		tok := l.NextToken()
+		for tok.Type != token.ILLEGAL && tok.Type != token.EOF {
+			tok = l.NextToken()
+		}
+		testIllegal(t, tok, test.literal, test.message)
+	}
+}
+
+func TestMalformedStringRecovery(t *testing.T) {
+	l := New(`"\q"; 5`)
+	expected := []token.TokenType{token.ILLEGAL, token.SEMICOLON, token.INT, token.EOF}
+	for _, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Errorf("Expected %s, got %s", tt, tok.Type)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 10 / 2; // trailing comment
+/* block
+   comment */ x /* inline */ * 2;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK, "*"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for _, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("Expected %s, got %s", test.expectedType, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("Expected %q, got %q", test.expectedLiteral, tok.Literal)
+		}
+	}
+
+	expectedComments := []struct {
+		literal string
+		line    int
+		column  int
+	}{
+		{"// leading comment", 1, 1},
+		{"// trailing comment", 2, 17},
+		{"/* block\n   comment */", 3, 1},
+		{"/* inline */", 4, 17},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("Expected %d comments, got %d", len(expectedComments), len(comments))
+	}
+	for i, expected := range expectedComments {
+		c := comments[i]
+		if c.Type != token.COMMENT || c.Literal != expected.literal {
+			t.Errorf("Expected COMMENT(%q), got %s", expected.literal, c)
+		}
+		if c.Pos.Line != expected.line || c.Pos.Column != expected.column {
+			t.Errorf("Expected %q to be at %d:%d, got %s", c.Literal, expected.line, expected.column, c.Pos)
+		}
+	}
+}
+
+func TestDropComments(t *testing.T) {
+	l := NewReader(strings.NewReader("// one\nx /* two */ + 1"))
+	l.DropComments()
+
+	expected := []token.TokenType{token.IDENT, token.PLUS, token.INT, token.EOF}
+	for _, tt := range expected {
+		if tok := l.NextToken(); tok.Type != tt {
+			t.Errorf("Expected %s, got %s", tt, tok)
+		}
+	}
+	if comments := l.Comments(); len(comments) != 0 {
+		t.Errorf("Expected no comments, got %v", comments)
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("5 /* never closed")
+	expected := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.ILLEGAL, "/* never closed"},
+		{token.EOF, ""},
+	}
+	for _, test := range expected {
+		tok := l.NextToken()
+		if test.expectedType == token.ILLEGAL && tok.Message != "unterminated block comment" {
+			t.Errorf("Expected message %q, got %q", "unterminated block comment", tok.Message)
+		}
+		if tok.Type != test.expectedType || tok.Literal != test.expectedLiteral {
+			t.Errorf("Expected %s(%s), got %s", test.expectedType, test.expectedLiteral, tok)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string // the message of an ILLEGAL token
+	}{
+		{"42", token.INT, "42"},
+		{"1.5", token.FLOAT, "1.5"},
+		{"0.25", token.FLOAT, "0.25"},
+		{"1e9", token.FLOAT, "1e9"},
+		{"2.5E-3", token.FLOAT, "2.5E-3"},
+		{"6.02e+23", token.FLOAT, "6.02e+23"},
+		{"1e", token.ILLEGAL, "malformed float literal: missing exponent digits in 1e"},
+		{"3e+", token.ILLEGAL, "malformed float literal: missing exponent digits in 3e+"},
+		{"1_000_000", token.INT, "1_000_000"},
+		{"1_000.000_1", token.FLOAT, "1_000.000_1"},
+		{"0xFF_ff", token.INT, "0xFF_ff"},
+		{"0X_1f", token.INT, "0X_1f"},
+		{"0o755", token.INT, "0o755"},
+		{"0b1010_0101", token.INT, "0b1010_0101"},
+		{"1__0", token.ILLEGAL, "malformed number literal: '_' must separate digits in 1__0"},
+		{"100_", token.ILLEGAL, "malformed number literal: '_' must separate digits in 100_"},
+		{"0x", token.ILLEGAL, "malformed number literal: no digits in 0x"},
+		{"0b102", token.ILLEGAL, "malformed number literal: invalid digit '2' in 0b102"},
+		{"0o8", token.ILLEGAL, "malformed number literal: invalid digit '8' in 0o8"},
+		{"0xF_", token.ILLEGAL, "malformed number literal: '_' must separate digits in 0xF_"},
+	}
+
+	for _, test := range tests {
+		l := New(test.input)
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("%s: Expected %s, got %s", test.input, test.expectedType, tok.Type)
+		}
+		if test.expectedType == token.ILLEGAL {
+			testIllegal(t, tok, test.input, test.expectedLiteral)
+		} else if tok.Literal != test.expectedLiteral {
+			t.Errorf("%s: Expected %q, got %q", test.input, test.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestReaderMatchesString(t *testing.T) {
+	input := `let 名前 = "世界\u{1F600}"; // comment
+let f = fn(x) { x * 1_000 + 0x1F }; /* block */ f(2.5e3);`
+
+	expected := New(input, "a.mk").Tokens()
+	actual := NewReader(iotest.OneByteReader(strings.NewReader(input)), "a.mk").Tokens()
+
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("Expected %s at %+v, got %s at %+v", expected[i], expected[i].Pos, actual[i], actual[i].Pos)
+		}
+	}
+	if expected[len(expected)-1].Type != token.EOF {
+		t.Errorf("Expected the last token to be EOF, got %s", expected[len(expected)-1])
+	}
+}
+
+func TestReaderBoundedBuffer(t *testing.T) {
+	line := "let x = x + 1;\n"
+	input := strings.Repeat(line, 10000)
+	l := NewReader(strings.NewReader(input))
+
+	count := 0
+	maxBuffered := 0
+	l.Each(func(tok token.Token) bool {
+		count++
+		if len(l.input) > maxBuffered {
+			maxBuffered = len(l.input)
+		}
+		return true
+	})
+
+	if count != 7*10000+1 {
+		t.Errorf("Expected %d tokens, got %d", 7*10000+1, count)
+	}
+	if maxBuffered > 2*readChunkSize {
+		t.Errorf("Expected at most %d bytes buffered, got %d", 2*readChunkSize, maxBuffered)
+	}
+}
+
+func TestEachStopsEarly(t *testing.T) {
+	l := New("1 2 3 4")
+	var literals []string
+	l.Each(func(tok token.Token) bool {
+		literals = append(literals, tok.Literal)
+		return len(literals) < 2
+	})
+	if strings.Join(literals, " ") != "1 2" {
+		t.Errorf("Expected to stop after 2 tokens, got %v", literals)
+	}
+	if tok := l.NextToken(); tok.Literal != "3" {
+		t.Errorf("Expected lexing to resume at 3, got %s", tok)
+	}
+}
+
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestReaderError(t *testing.T) {
+	errBroken := errors.New("broken pipe")
+	l := NewReader(&failingReader{data: "let x", err: errBroken})
+
+	expected := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ILLEGAL, ""},
+		{token.EOF, ""},
+		{token.EOF, ""},
+	}
+	for _, test := range expected {
+		tok := l.NextToken()
+		if test.expectedType == token.ILLEGAL && tok.Message != "read error: broken pipe" {
+			t.Errorf("Expected message %q, got %q", "read error: broken pipe", tok.Message)
+		}
+		if tok.Type != test.expectedType || tok.Literal != test.expectedLiteral {
+			t.Errorf("Expected %s(%s), got %s", test.expectedType, test.expectedLiteral, tok)
+		}
+	}
+	if l.Err() != errBroken {
+		t.Errorf("Expected Err() to be %v, got %v", errBroken, l.Err())
+	}
+
+	if NewReader(strings.NewReader("x")).Err() != nil {
+		t.Errorf("Expected io.EOF not to be reported")
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := `a % b <= c >= d ** e & f | g ^ ~h << i >> j && k || l < m > n * o
+p += 1; p -= 2; p *= 3; p /= 4; p %= 5
+q.r 1.5.s ...t
+match u => v == w |> x`
+
+	expected := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.MODULO, "%"},
+		{token.IDENT, "b"},
+		{token.LTE, "<="},
+		{token.IDENT, "c"},
+		{token.GTE, ">="},
+		{token.IDENT, "d"},
+		{token.POWER, "**"},
+		{token.IDENT, "e"},
+		{token.BIT_AND, "&"},
+		{token.IDENT, "f"},
+		{token.BIT_OR, "|"},
+		{token.IDENT, "g"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.IDENT, "h"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.IDENT, "i"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.IDENT, "j"},
+		{token.AND, "&&"},
+		{token.IDENT, "k"},
+		{token.OR, "||"},
+		{token.IDENT, "l"},
+		{token.LT, "<"},
+		{token.IDENT, "m"},
+		{token.GT, ">"},
+		{token.IDENT, "n"},
+		{token.ASTERISK, "*"},
+		{token.IDENT, "o"},
+		{token.IDENT, "p"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "p"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "p"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "p"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "p"},
+		{token.MODULO_ASSIGN, "%="},
+		{token.INT, "5"},
+		{token.IDENT, "q"},
+		{token.DOT, "."},
+		{token.IDENT, "r"},
+		{token.FLOAT, "1.5"},
+		{token.DOT, "."},
+		{token.IDENT, "s"},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "t"},
+		{token.MATCH, "match"},
+		{token.IDENT, "u"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "v"},
+		{token.EQ, "=="},
+		{token.IDENT, "w"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for _, test := range expected {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType || tok.Literal != test.expectedLiteral {
+			t.Errorf("Expected %s(%s), got %s", test.expectedType, test.expectedLiteral, tok)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"Hello ${name}, you have ${count + 1} items" "${a}${b}" "nested ${f({"k": "${x}"})}!" "\${raw}"`
+
+	expected := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INTERP_START, "Hello "},
+		{token.IDENT, "name"},
+		{token.INTERP_MID, ", you have "},
+		{token.IDENT, "count"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.INTERP_END, " items"},
+		{token.INTERP_START, ""},
+		{token.IDENT, "a"},
+		{token.INTERP_MID, ""},
+		{token.IDENT, "b"},
+		{token.INTERP_END, ""},
+		{token.INTERP_START, "nested "},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.INTERP_START, ""},
+		{token.IDENT, "x"},
+		{token.INTERP_END, ""},
+		{token.RBRACE, "}"},
+		{token.RPAREN, ")"},
+		{token.INTERP_END, "!"},
+		{token.STRING, "${raw}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for _, test := range expected {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType || tok.Literal != test.expectedLiteral {
+			t.Errorf("Expected %s(%s), got %s", test.expectedType, test.expectedLiteral, tok)
+		}
+	}
+}
+
+func TestRawStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string // the message of an ILLEGAL token
+	}{
+		{"`plain`", token.STRING, "plain"},
+		{"`C:\\path\\n ${x} \"quoted\"`", token.STRING, `C:\path\n ${x} "quoted"`},
+		{"`line 1\r\nline 2`", token.STRING, "line 1\nline 2"},
+		{"`never closed", token.ILLEGAL, "unterminated raw string literal"},
+		{`""""""`, token.STRING, ""},
+		{`"""one line"""`, token.STRING, "one line"},
+		{"\"\"\"\n    SELECT *\n      FROM users\n\n    WHERE id = ${id}\n    \"\"\"", token.STRING, "SELECT *\n  FROM users\n\nWHERE id = ${id}"},
+		{"\"\"\"\n\t{\n\t  \"a\": \"\\n\"\n\t}\"\"\"", token.STRING, "{\n  \"a\": \"\\n\"\n}"},
+		{"\"\"\"\n  a \"quote\" \"\" here\n  \"\"\"", token.STRING, `a "quote" "" here`},
+		{`"""never closed""`, token.ILLEGAL, "unterminated text block"},
+		{`""`, token.STRING, ""},
+	}
+
+	for _, test := range tests {
+		for _, l := range []*Lexer{New(test.input), NewReader(iotest.OneByteReader(strings.NewReader(test.input)))} {
+			tok := l.NextToken()
+			if tok.Type != test.expectedType {
+				t.Errorf("%q: Expected %s, got %s", test.input, test.expectedType, tok.Type)
+			}
+			if test.expectedType == token.ILLEGAL {
+				testIllegal(t, tok, test.input, test.expectedLiteral)
+			} else if tok.Literal != test.expectedLiteral {
+				t.Errorf("%q: Expected %q, got %q", test.input, test.expectedLiteral, tok.Literal)
+			}
+			if next := l.NextToken(); next.Type != token.EOF {
+				t.Errorf("%q: Expected EOF after the string, got %s", test.input, next)
+			}
+		}
+	}
+}
+
+func TestRawStringPositions(t *testing.T) {
+	l := New("`a\nb` x")
+	l.NextToken()
+	tok := l.NextToken()
+	if tok.Pos.Line != 2 || tok.Pos.Column != 4 {
+		t.Errorf("Expected x to be at 2:4, got %s", tok.Pos)
+	}
+}
+
+func TestCharLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string // the message of an ILLEGAL token
+	}{
+		{`'a'`, token.CHAR, "a"},
+		{`'日'`, token.CHAR, "日"},
+		{`'\n'`, token.CHAR, "\n"},
+		{`'\''`, token.CHAR, "'"},
+		{`'"'`, token.CHAR, `"`},
+		{`'\u{1F600}'`, token.CHAR, "😀"},
+		{`''`, token.ILLEGAL, "empty character literal"},
+		{`'ab'`, token.ILLEGAL, "character literal must contain exactly one character"},
+		{`'a`, token.ILLEGAL, "unterminated character literal"},
+		{`'\q'`, token.ILLEGAL, `invalid escape sequence: \q`},
+	}
+
+	for _, test := range tests {
+		l := New(test.input)
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("%s: Expected %s, got %s", test.input, test.expectedType, tok.Type)
+		}
+		if test.expectedType == token.ILLEGAL {
+			testIllegal(t, tok, test.input, test.expectedLiteral)
+		} else if tok.Literal != test.expectedLiteral {
+			t.Errorf("%s: Expected %q, got %q", test.input, test.expectedLiteral, tok.Literal)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("%s: Expected EOF after the literal, got %s", test.input, next)
+		}
+	}
+}
+
+// testIllegal checks that tok is an ILLEGAL token holding the source text
+// literal and the error message.
+func testIllegal(t *testing.T, tok token.Token, literal, message string) {
+	if tok.Type != token.ILLEGAL {
+		t.Errorf("%s: Expected ILLEGAL, got %s", literal, tok.Type)
+		return
+	}
+	if tok.Literal != literal {
+		t.Errorf("%s: Expected literal %q, got %q", literal, literal, tok.Literal)
+	}
+	if tok.Message != message {
+		t.Errorf("%s: Expected message %q, got %q", literal, message, tok.Message)
+	}
+}
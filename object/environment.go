@@ -31,3 +31,17 @@ func (e *Environment) Set(name string, obj Object) Object {
 	e.store[name] = obj
 	return obj
 }
+
+// Assign updates the binding of name in the nearest environment that defines
+// it. It reports false, leaving every environment untouched, if name is not
+// defined anywhere.
+func (e *Environment) Assign(name string, obj Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = obj
+		return obj, true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, obj)
+	}
+	return nil, false
+}
@@ -7,18 +7,23 @@ import (
 	"strings"
 
 	"github.com/wawoon/monkeylang/ast"
+	"github.com/wawoon/monkeylang/token"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJECT  ObjectType = "INTEGER"
+	FLOAT_OBJECT    ObjectType = "FLOAT"
 	BOOLEAN_OBJECT  ObjectType = "BOOLEAN"
 	NULL_OBJECT     ObjectType = "NULL"
 	RETURN_OBJECT   ObjectType = "RETURN"
+	BREAK_OBJECT    ObjectType = "BREAK"
+	CONTINUE_OBJECT ObjectType = "CONTINUE"
 	ERROR_OBJECT    ObjectType = "ERROR"
 	FUNCTION_OBJECT ObjectType = "FUNCTION"
 	STRING_OBJECT   ObjectType = "STRING"
+	CHAR_OBJECT     ObjectType = "CHAR"
 	BUILTIN_OBJECT  ObjectType = "BUILTIN"
 	ARRAY_OBJECT    ObjectType = "ARRAY"
 	HASH_OBJECT     ObjectType = "HASH"
@@ -41,6 +46,27 @@ func (i Integer) Inspect() string {
 	return strconv.FormatInt(i.Value, 10)
 }
 
+// Float is an IEEE 754 double. Arithmetic follows IEEE rules, so dividing by
+// zero gives an infinity or NaN instead of an error. Floats are not Hashable
+// because NaN is never equal to itself.
+type Float struct {
+	Value float64
+}
+
+func (f Float) Type() ObjectType {
+	return FLOAT_OBJECT
+}
+
+// Inspect always shows a fraction or exponent so that floats can be told
+// apart from integers, e.g. 2.0 rather than 2.
+func (f Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -73,19 +99,38 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Break and Continue are the signals of break and continue statements. Like
+// ReturnValue they travel up through the enclosing blocks until the loop that
+// handles them.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJECT }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJECT }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
 	Message string
+	Pos     token.Position
 }
 
 func (e Error) Type() ObjectType {
 	return ERROR_OBJECT
 }
 func (e Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "Error: " + e.Pos.String() + ": " + e.Message
+	}
 	return "Error: " + e.Message
 }
 
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -96,8 +141,15 @@ func (f *Function) Type() ObjectType {
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		param := p.String()
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			param += " = " + f.Defaults[i].String()
+		}
+		params = append(params, param)
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
 	}
 
 	out.WriteString("fn")
@@ -116,10 +168,39 @@ type String struct {
 func (s String) Type() ObjectType { return STRING_OBJECT }
 func (s *String) Inspect() string { return s.Value }
 
+// Char is a single Unicode code point, as produced by a 'c' literal or by
+// indexing a string.
+type Char struct {
+	Value rune
+}
+
+func (c *Char) Type() ObjectType { return CHAR_OBJECT }
+func (c *Char) Inspect() string  { return string(c.Value) }
+
 type BuiltinFunction func(args ...Object) Object
 
+// BuiltinOptionsFunction is a builtin that also takes keyword arguments.
+// options holds the ones given at the call site, keyed by name.
+type BuiltinOptionsFunction func(options map[string]Object, args ...Object) Object
+
+// Builtin is a function implemented in Go. Builtins that take keyword
+// arguments set OptionsFn instead of Fn and list the names they accept in
+// Options.
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn        BuiltinFunction
+	OptionsFn BuiltinOptionsFunction
+	Options   []string
+}
+
+// AcceptsOption reports whether name is one of the builtin's keyword
+// arguments.
+func (b *Builtin) AcceptsOption(name string) bool {
+	for _, option := range b.Options {
+		if option == name {
+			return true
+		}
+	}
+	return false
 }
 
 func (b Builtin) Type() ObjectType { return BUILTIN_OBJECT }
@@ -172,20 +253,40 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+func (c *Char) HashKey() HashKey {
+	return HashKey{Type: c.Type(), Value: uint64(c.Value)}
+}
+
 type HashPair struct {
 	Key   Object
 	Value Object
 }
 
+// Hash remembers the order in which keys were first inserted. Keys lists
+// the keys of Pairs in that order; updating an existing key keeps its place.
+// Use Set to add pairs so that both stay in sync.
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.Keys = append(h.Keys, key)
+	}
+	h.Pairs[key] = pair
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJECT }
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, key := range h.Keys {
+		pair := h.Pairs[key]
 		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
 	}
 	out.WriteString("{")
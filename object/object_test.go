@@ -2,6 +2,22 @@ package object
 
 import "testing"
 
+func TestHashInsertionOrder(t *testing.T) {
+	h := NewHash()
+	for _, key := range []string{"b", "c", "a", "c"} {
+		k := &String{Value: key}
+		h.Set(k.HashKey(), HashPair{Key: k, Value: &String{Value: key + key}})
+	}
+
+	expected := `{b: bb, c: cc, a: aa}`
+	if h.Inspect() != expected {
+		t.Errorf("Inspect: expected %s, got %s", expected, h.Inspect())
+	}
+	if len(h.Keys) != len(h.Pairs) {
+		t.Errorf("Keys and Pairs out of sync: %d keys, %d pairs", len(h.Keys), len(h.Pairs))
+	}
+}
+
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "hello"}
 	hello2 := &String{Value: "hello"}
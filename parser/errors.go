@@ -0,0 +1,17 @@
+package parser
+
+import "github.com/wawoon/monkeylang/token"
+
+// ParseError describes a syntax error. Actual is the offending token and
+// Expected lists the token types that would have been accepted in its place,
+// if the parser was looking for specific ones.
+type ParseError struct {
+	Pos      token.Position
+	Expected []token.TokenType
+	Actual   token.Token
+	Message  string
+}
+
+func (e *ParseError) Error() string {
+	return e.Pos.String() + ": " + e.Message
+}
@@ -66,6 +66,21 @@ func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 	return true
 }
 
+func testFloatLiteral(t *testing.T, exp ast.Expression, value float64) bool {
+	fl, ok := exp.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("testFloatLiteral: expression should be of type FloatLiteral, got %T", exp)
+		return false
+	}
+
+	if fl.Value != value {
+		t.Fatalf("testFloatLiteral: float literal value should be %g, got %g", value, fl.Value)
+		return false
+	}
+
+	return true
+}
+
 func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
 	ident, ok := exp.(*ast.Identifier)
 	if !ok {
@@ -112,6 +127,8 @@ func testLiteralExpression(t *testing.T, exp ast.Expression, expected interface{
 		return testIntegerLiteral(t, exp, int64(v))
 	case int64:
 		return testIntegerLiteral(t, exp, v)
+	case float64:
+		return testFloatLiteral(t, exp, v)
 	case string:
 		return testIdentifier(t, exp, v)
 	case bool:
@@ -182,8 +199,8 @@ func checkParserError(t *testing.T, p *Parser) {
 	}
 
 	t.Errorf("parser has %d errors", len(errs))
-	for _, msg := range errs {
-		t.Errorf("parser error: %s", msg)
+	for _, err := range errs {
+		t.Errorf("parser error: %s", err)
 	}
 	t.FailNow()
 }
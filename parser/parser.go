@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/wawoon/monkeylang/ast"
 	"github.com/wawoon/monkeylang/lexer"
@@ -14,29 +17,62 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
+// Precedences from loosest to tightest binding. Bitwise operators bind
+// tighter than comparisons, so x & 1 == 0 means (x & 1) == 0. ** is right
+// associative and binds tighter than prefix operators, so -2 ** 2 is -(2 ** 2).
+// Assignment is the loosest and right associative, so a = b = 1 sets both.
+// The pipeline operator comes next, so x || y |> f is f(x || y).
 const (
 	_ int = iota
 	LOWEST
-	EQUALS      // ==
-	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
-	PREFIX      // -X or !X
+	ASSIGN      // =, +=, -=, *=, /= or %=
+	PIPE        // |>
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
+	EQUALS      // == or !=
+	LESSGREATER // >, <, >= or <=
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
+	SHIFT       // << or >>
+	SUM         // + or -
+	PRODUCT     // *, / or %
+	PREFIX      // -X, !X or ~X
+	POWER       // **
 	CALL        // myFunc(X)
-	INDEX       // myArray[X]
+	INDEX       // myArray[X] or myHash.X
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.MODULO_ASSIGN:   ASSIGN,
+	token.PIPE:            PIPE,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LTE:             LESSGREATER,
+	token.GTE:             LESSGREATER,
+	token.BIT_OR:          BIT_OR,
+	token.BIT_XOR:         BIT_XOR,
+	token.BIT_AND:         BIT_AND,
+	token.SHIFT_LEFT:      SHIFT,
+	token.SHIFT_RIGHT:     SHIFT,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.MODULO:          PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.DOT:             INDEX,
 }
 
 type Parser struct {
@@ -44,7 +80,31 @@ type Parser struct {
 
 	curToken  token.Token
 	peekToken token.Token
-	errors    []string
+	errors    []*ParseError
+
+	// ahead holds the tokens after peekToken that peekAhead has already read
+	// from the lexer.
+	ahead []token.Token
+
+	// depth is the number of unclosed braces before curToken and nesting the
+	// number of unclosed parentheses, brackets and braces. Both are kept by
+	// nextToken.
+	depth   int
+	nesting int
+
+	// recovering is set by the first error in a statement; further errors
+	// are dropped until the parser has skipped to the end of that statement.
+	recovering bool
+
+	// loopDepth counts the loops enclosing the current statement within the
+	// current function, to reject break and continue outside of a loop.
+	loopDepth int
+
+	// guardNesting is nesting at the start of the match guard being parsed,
+	// where an => ends the guard instead of starting an arrow function. It is
+	// 0 outside of a guard, as a guard is always inside the braces of its
+	// match.
+	guardNesting int
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFn   map[token.TokenType]infixParseFn
@@ -53,34 +113,59 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*ParseError{},
 	}
 	p.prefixParseFns = map[token.TokenType]prefixParseFn{}
 	p.infixParseFn = map[token.TokenType]infixParseFn{}
 
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.TRUE, p.parseBooleanLiteral)
 	p.registerPrefix(token.FALSE, p.parseBooleanLiteral)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.INTERP_START, p.parseInterpolatedString)
+	p.registerPrefix(token.CHAR, p.parseCharLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.MODULO, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LTE, p.parseInfixExpression)
+	p.registerInfix(token.GTE, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MODULO_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PIPE, p.parsePipeExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -88,8 +173,33 @@ func New(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) nextToken() {
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.depth++
+		p.nesting++
+	case token.RBRACE:
+		p.depth--
+		p.nesting--
+	case token.LPAREN, token.LBRACKET:
+		p.nesting++
+	case token.RPAREN, token.RBRACKET:
+		p.nesting--
+	}
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	if len(p.ahead) > 0 {
+		p.peekToken = p.ahead[0]
+		p.ahead = p.ahead[1:]
+	} else {
+		p.peekToken = p.l.NextToken()
+	}
+}
+
+// peekAhead returns the token n positions after peekToken.
+func (p *Parser) peekAhead(n int) token.Token {
+	for len(p.ahead) < n {
+		p.ahead = append(p.ahead, p.l.NextToken())
+	}
+	return p.ahead[n-1]
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -103,19 +213,71 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+
+	for _, c := range p.l.Comments() {
+		program.Comments = append(program.Comments, &ast.Comment{Token: c})
+	}
 	return program
 }
 
+// parseStatement parses one statement. A statement containing a syntax error
+// is dropped and the parser skips ahead to its end, so that one mistake is
+// reported once and parsing resumes with the next statement.
 func (p *Parser) parseStatement() ast.Statement {
+	depth := p.depth
+	stmt := p.parseStatementKind()
+	if !p.recovering {
+		return stmt
+	}
+
+	p.synchronize(depth)
+	p.recovering = false
+	return nil
+}
+
+// synchronize advances to the end of a statement that began at the given
+// brace depth: either its terminating semicolon, a closing brace back at
+// that depth that is not followed by more of the statement, as in
+// while (x) { ... } without a semicolon, or the token before the brace that
+// closes the enclosing block. If the closing brace is already the current
+// token it is left there for parseBlockStatement.
+func (p *Parser) synchronize(depth int) {
+	for !p.curTokenIs(token.EOF) {
+		if p.depth <= depth && (p.curTokenIs(token.SEMICOLON) || p.curTokenIs(token.RBRACE)) {
+			return
+		}
+		if p.depth == depth+1 && p.curTokenIs(token.RBRACE) && !p.peekContinuesStatement() {
+			return
+		}
+
+		next := p.depth
+		switch p.curToken.Type {
+		case token.LBRACE:
+			next++
+		case token.RBRACE:
+			next--
+		}
+		if next <= depth && (p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF)) {
+			return
+		}
+		p.nextToken()
+	}
+}
+
+func (p *Parser) parseStatementKind() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
 	// case token.IF:
 	// 	return p.parseIfStatement()
-	// case token.WHILE:
-	// 	return p.parseWhileStatement()
 	// case token.FUNCTION:
 	// 	return p.parseFunctionStatement()
 	// case token.PRINT:
@@ -129,11 +291,19 @@ func (p *Parser) parseStatement() ast.Statement {
 
 func (p *Parser) parseLetStatement() ast.Statement {
 	stmt := &ast.LetStatement{Token: p.curToken}
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern(false)
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -148,18 +318,230 @@ func (p *Parser) parseLetStatement() ast.Statement {
 	return stmt
 }
 
+// parsePattern parses the pattern starting at the current token: an
+// identifier, an array pattern or a hash pattern. If literals is set, as in
+// the arms of a match expression, literals such as 1, -2.5, "a", 'b' and true
+// are accepted as well, at any level.
+func (p *Parser) parsePattern(literals bool) ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.LBRACKET:
+		return p.parseArrayPattern(literals)
+	case token.LBRACE:
+		return p.parseHashPattern(literals)
+	}
+
+	if literals {
+		switch p.curToken.Type {
+		case token.INT, token.FLOAT, token.STRING, token.CHAR, token.TRUE, token.FALSE:
+			value := p.prefixParseFns[p.curToken.Type]()
+			if value == nil {
+				return nil
+			}
+			return &ast.LiteralPattern{Value: value}
+		case token.MINUS:
+			if p.peekTokenIs(token.INT) || p.peekTokenIs(token.FLOAT) {
+				return &ast.LiteralPattern{Value: p.parsePrefixExpression()}
+			}
+		}
+	}
+
+	p.errorf(p.curToken, "Expected a pattern, but got %s", p.curToken)
+	return nil
+}
+
+func (p *Parser) parseArrayPattern(literals bool) ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			// the rest element must be the last one
+			break
+		}
+
+		element := ast.PatternElement{Target: p.parsePattern(literals)}
+		if element.Target == nil {
+			return nil
+		}
+		element.Default = p.parsePatternDefault()
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+func (p *Parser) parseHashPattern(literals bool) ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		var pair ast.HashPatternPair
+		switch {
+		case p.peekTokenIs(token.IDENT):
+			p.nextToken()
+			key := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			pair.Key, pair.Target = key, key
+		case p.peekTokenIs(token.STRING):
+			// a string key has no shorthand form
+			p.nextToken()
+			pair.Key = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.peekTokenIs(token.COLON) {
+				p.peekError(token.COLON)
+				return nil
+			}
+		default:
+			p.peekError(token.IDENT, token.STRING)
+			return nil
+		}
+
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			pair.Target = p.parsePattern(literals)
+			if pair.Target == nil {
+				return nil
+			}
+		}
+		pair.Default = p.parsePatternDefault()
+		pattern.Pairs = append(pattern.Pairs, pair)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return pattern
+}
+
+// parsePatternDefault parses the optional "= value" after a pattern element.
+func (p *Parser) parsePatternDefault() ast.Expression {
+	if !p.peekTokenIs(token.ASSIGN) {
+		return nil
+	}
+	p.nextToken()
+	p.nextToken()
+	return p.parseExpression(LOWEST)
+}
+
 func (p *Parser) parseReturnStatement() ast.Statement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	if !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loopDepth++
+	stmt.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
+
 	return stmt
 }
 
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loopDepth++
+	stmt.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+	if p.loopDepth == 0 {
+		p.errorf(tok, "%s outside of a loop", tok.Literal)
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
 func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
@@ -173,7 +555,7 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
-		p.noPrefixParseFnError(p.curToken.Type)
+		p.noPrefixParseFnError(p.curToken)
 		return nil
 	}
 	leftExp := prefix()
@@ -191,24 +573,54 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
+	if p.peekTokenIs(token.ARROW) && p.arrowAllowed() {
+		return p.parseArrowFunction()
+	}
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	val, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
+	literal := strings.ReplaceAll(p.curToken.Literal, "_", "")
+	base := 10
+	if len(literal) > 2 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			literal = literal[2:]
+		}
+	}
+
+	val, err := strconv.ParseInt(literal, base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		p.errorf(p.curToken, "Integer literal %s overflows int64", p.curToken.Literal)
+		return nil
+	}
 	if err != nil {
-		msg := fmt.Sprintf("Expected next token to be an integer, but got %s", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorf(p.curToken, "Expected next token to be an integer, but got %s", p.curToken.Literal)
 		return nil
 	}
 	return &ast.IntegerLiteral{Token: p.curToken, Value: val}
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	val, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.errorf(p.curToken, "Float literal %s is out of range", p.curToken.Literal)
+		return nil
+	}
+	return &ast.FloatLiteral{Token: p.curToken, Value: val}
+}
+
 func (p *Parser) parseBooleanLiteral() ast.Expression {
 	val, err := strconv.ParseBool(p.curToken.Literal)
 	if err != nil {
-		msg := fmt.Sprintf("Expected next token to be an bool, but got %s", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorf(p.curToken, "Expected next token to be an bool, but got %s", p.curToken.Literal)
 		return nil
 	}
 	return &ast.Boolean{Token: p.curToken, Value: val}
@@ -218,6 +630,44 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseCharLiteral() ast.Expression {
+	ch, _ := utf8.DecodeRuneInString(p.curToken.Literal)
+	return &ast.CharLiteral{Token: p.curToken, Value: ch}
+}
+
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+
+	for {
+		if p.curToken.Literal != "" {
+			segment := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			str.Parts = append(str.Parts, segment)
+		}
+		if p.curTokenIs(token.INTERP_END) {
+			return str
+		}
+
+		if p.peekTokenIs(token.INTERP_MID) || p.peekTokenIs(token.INTERP_END) {
+			p.errorf(p.peekToken, "Expected an expression inside ${...}")
+			return nil
+		}
+		p.nextToken()
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.INTERP_MID) && !p.expectPeek(token.INTERP_END) {
+			return nil
+		}
+		if p.peekTokenIs(token.INTERP_MID) {
+			p.nextToken()
+		}
+	}
+}
+
+func (p *Parser) parseIllegal() ast.Expression {
+	p.errorf(p.curToken, "Illegal token: %s", p.curToken.Message)
+	return nil
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
@@ -227,7 +677,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Pairs = []ast.HashPair{}
 
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
@@ -238,7 +688,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		}
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -270,6 +720,46 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Left:     left,
 	}
 
+	precedence := p.curPrecedence()
+	if p.curTokenIs(token.POWER) {
+		// right associative: 2 ** 3 ** 2 is 2 ** (3 ** 2)
+		precedence--
+	}
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+
+	return expression
+}
+
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+	}
+
+	switch left.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.MemberExpression:
+		expression.Target = left
+	default:
+		if left != nil {
+			p.errorf(p.curToken, "Cannot assign to %s", left.String())
+		}
+		return nil
+	}
+
+	// right associative: a = b = 1 is a = (b = 1)
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	expression := &ast.PipeExpression{
+		Token: p.curToken,
+		Left:  left,
+	}
+
 	precedence := p.curPrecedence()
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
@@ -278,6 +768,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	if p.arrowAllowed() && p.arrowParametersAhead() {
+		return p.parseArrowFunction()
+	}
+
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
@@ -322,31 +816,127 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// peekContinuesStatement reports whether peekToken can continue the
+// statement ending at curToken rather than start a new one.
+func (p *Parser) peekContinuesStatement() bool {
+	switch p.peekToken.Type {
+	case token.SEMICOLON, token.ELSE, token.COMMA, token.COLON, token.RPAREN, token.RBRACKET:
+		return true
+	}
+	_, ok := p.infixParseFn[p.peekToken.Type]
+	return ok
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
 	p.nextToken()
+	depth := p.depth
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
+		if p.curTokenIs(token.RBRACE) && p.depth <= depth {
+			// error recovery stopped on the closing brace of this block
+			break
+		}
 		p.nextToken()
 	}
 	return block
 }
 
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return expression
+}
+
+// parseMatchArm parses pattern [if guard] => result. The result is either a
+// block or a single expression; a hash literal result must be parenthesized.
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern(true)}
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		guardNesting := p.guardNesting
+		p.guardNesting = p.nesting
+		arm.Guard = p.parseExpression(LOWEST)
+		p.guardNesting = guardNesting
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	p.nextToken()
+
+	arm.Body = p.parseBlockOrExpression()
+	return arm
+}
+
+// parseBlockOrExpression parses a block, or a single expression wrapped in a
+// block when curToken is not an opening brace.
+func (p *Parser) parseBlockOrExpression() *ast.BlockStatement {
+	if p.curTokenIs(token.LBRACE) {
+		return p.parseBlockStatement()
+	}
+
+	tok := p.curToken
+	stmt := &ast.ExpressionStatement{Token: tok, Expression: p.parseExpression(LOWEST)}
+	return &ast.BlockStatement{Token: tok, Statements: []ast.Statement{stmt}}
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	expression := &ast.FunctionLiteral{
 		Token: p.curToken,
 	}
 
+	// a loop around the function literal cannot be left from inside it,
+	// including from its default values
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 
-	expression.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(expression) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -357,29 +947,115 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return expression
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return identifiers
+// parseArrowFunction parses (params) => body or param => body, starting at
+// the opening parenthesis or the single parameter. It yields the same
+// FunctionLiteral as fn(params) { body }. The body is a block or a single
+// expression; a hash literal body must be parenthesized.
+func (p *Parser) parseArrowFunction() ast.Expression {
+	expression := &ast.FunctionLiteral{
+		Token: token.Token{Type: token.FUNCTION, Literal: "fn", Pos: p.curToken.Pos},
+	}
+
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
+	if p.curTokenIs(token.IDENT) {
+		expression.Parameters = []*ast.Identifier{{Token: p.curToken, Value: p.curToken.Literal}}
+		expression.Defaults = []ast.Expression{nil}
+	} else if !p.parseFunctionParameters(expression) {
+		return nil
 	}
 
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
 	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
+	expression.Body = p.parseBlockOrExpression()
+
+	return expression
+}
+
+// arrowAllowed reports whether an => after curToken may start an arrow
+// function rather than end a match guard.
+func (p *Parser) arrowAllowed() bool {
+	return p.guardNesting == 0 || p.nesting != p.guardNesting
+}
+
+// arrowParametersAhead reports whether the parenthesis at curToken opens
+// the parameter list of an arrow function, that is whether its closing
+// parenthesis is followed by =>.
+func (p *Parser) arrowParametersAhead() bool {
+	switch p.peekToken.Type {
+	case token.IDENT, token.ELLIPSIS, token.RPAREN:
+	default:
+		return false
+	}
+
+	open := 0
+	tok := p.peekToken
+	for i := 1; tok.Type != token.EOF; i++ {
+		switch tok.Type {
+		case token.LPAREN:
+			open++
+		case token.RPAREN:
+			if open == 0 {
+				return p.peekAhead(i).Type == token.ARROW
+			}
+			open--
+		}
+		tok = p.peekAhead(i)
+	}
+	return false
+}
+
+// parseFunctionParameters parses the parameter list of fn up to and
+// including the closing parenthesis: required parameters, then parameters
+// with a default value, then an optional ...rest parameter.
+func (p *Parser) parseFunctionParameters(fn *ast.FunctionLiteral) bool {
+	fn.Parameters = []*ast.Identifier{}
+	fn.Defaults = []ast.Expression{}
+	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
+		return true
+	}
+
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			fn.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			// the rest parameter must be the last one
+			return p.expectPeek(token.RPAREN)
+		}
 
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
-	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+		var def ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			def = p.parseExpression(LOWEST)
+		} else if n := len(fn.Defaults); n > 0 && fn.Defaults[n-1] != nil {
+			p.errorf(ident.Token, "Parameter %s without a default follows a parameter with one", ident.Value)
+			return false
+		}
+		fn.Parameters = append(fn.Parameters, ident)
+		fn.Defaults = append(fn.Defaults, def)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
-	return identifiers
+
+	return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -387,10 +1063,53 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 		Token:    p.curToken,
 		Function: function,
 	}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	if !p.parseCallArguments(exp) {
+		return nil
+	}
 	return exp
 }
 
+// parseCallArguments parses the arguments of call up to and including the
+// closing parenthesis. An identifier followed by a colon starts a named
+// argument; once one is seen, every later argument must be named too.
+func (p *Parser) parseCallArguments(call *ast.CallExpression) bool {
+	call.Arguments = []ast.Expression{}
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return true
+	}
+
+	seen := map[string]bool{}
+	for {
+		p.nextToken()
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+			name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if seen[name.Value] {
+				p.errorf(name.Token, "Duplicate named argument %s", name.Value)
+				return false
+			}
+			seen[name.Value] = true
+
+			p.nextToken()
+			p.nextToken()
+			arg := ast.NamedArgument{Name: name, Value: p.parseExpression(LOWEST)}
+			call.NamedArguments = append(call.NamedArguments, arg)
+		} else if len(call.NamedArguments) > 0 {
+			p.errorf(p.curToken, "Positional argument after named arguments")
+			return false
+		} else {
+			call.Arguments = append(call.Arguments, p.parseExpression(LOWEST))
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	return p.expectPeek(token.RPAREN)
+}
+
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	args := []ast.Expression{}
 	if p.peekTokenIs(end) {
@@ -426,6 +1145,20 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{
+		Token:  p.curToken,
+		Object: left,
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -458,18 +1191,46 @@ func (p *Parser) peekPrecedence() int {
 	return LOWEST
 }
 
-func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("Expected a prefix parse function for %s, but none was found", t)
-	p.errors = append(p.errors, msg)
+func (p *Parser) noPrefixParseFnError(t token.Token) {
+	p.errorf(t, "Expected a prefix parse function for %s, but none was found", t.Type)
 }
 
-func (p *Parser) Errors() []string {
+// Errors returns the syntax errors found so far, at most one per statement.
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
-func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("Expected next token to be %s, but got %s", t, p.peekToken)
-	p.errors = append(p.errors, msg)
+// peekError reports that the next token is none of the expected types.
+func (p *Parser) peekError(expected ...token.TokenType) {
+	names := make([]string, len(expected))
+	for i, t := range expected {
+		names[i] = t.String()
+	}
+	p.addError(&ParseError{
+		Pos:      p.peekToken.Pos,
+		Expected: expected,
+		Actual:   p.peekToken,
+		Message:  fmt.Sprintf("Expected next token to be %s, but got %s", strings.Join(names, " or "), p.peekToken),
+	})
+}
+
+// errorf records an error about tok.
+func (p *Parser) errorf(tok token.Token, format string, a ...interface{}) {
+	p.addError(&ParseError{
+		Pos:     tok.Pos,
+		Actual:  tok,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// addError records err unless the current statement already has an error,
+// and puts the parser into recovery mode.
+func (p *Parser) addError(err *ParseError) {
+	if p.recovering {
+		return
+	}
+	p.errors = append(p.errors, err)
+	p.recovering = true
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
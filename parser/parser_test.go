@@ -5,6 +5,7 @@ import (
 
 	"github.com/wawoon/monkeylang/ast"
 	"github.com/wawoon/monkeylang/lexer"
+	"github.com/wawoon/monkeylang/token"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -43,6 +44,102 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = xs;", "let [a, b] = xs;"},
+		{"let [head, ...tail] = xs;", "let [head, ...tail] = xs;"},
+		{"let [a, b = 1 + 2] = xs;", "let [a, b = (1 + 2)] = xs;"},
+		{"let [] = xs;", "let [] = xs;"},
+		{"let {host, port} = config;", "let {host, port} = config;"},
+		{"let {host: h, port = 80} = config;", "let {host: h, port = 80} = config;"},
+		{"let {db: {host}, tags: [first, ...rest]} = config;", "let {db: {host}, tags: [first, ...rest]} = config;"},
+		{"let [{a}, [b, c]] = xs;", "let [{a}, [b, c]] = xs;"},
+		{`let {"content-type": ct, "n": {m}} = headers;`, `let {"content-type": ct, "n": {m}} = headers;`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserError(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("ParseProgram: expected a LetStatement, got %T", program.Statements[0])
+		}
+		if stmt.Name != nil || stmt.Pattern == nil {
+			t.Errorf("ParseProgram: expected a pattern and no name for %q", tt.input)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("ParseProgram: expected %q, got %q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestMatchExpressionParsing(t *testing.T) {
+	input := `match (value) {
+		0 => "zero",
+		-1 => "minus one",
+		[x, y] => x + y,
+		[head, ...tail] if len(tail) > 1 => { let n = len(tail); n },
+		{"type": "user", "name": n} => n,
+		'c' => true,
+		_ if n > 3 => ({"big": n}),
+	}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserError(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("ParseProgram: expected 1 statements, got %d", len(program.Statements))
+	}
+	me, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("ParseProgram: expected a MatchExpression, got %T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	testIdentifier(t, me.Value, "value")
+
+	expected := []struct {
+		pattern string
+		guard   string
+		body    string
+	}{
+		{"0", "", "zero"},
+		{"(-1)", "", "minus one"},
+		{"[x, y]", "", "(x + y)"},
+		{"[head, ...tail]", "(len(tail) > 1)", "let n = len(tail);n"},
+		{`{"type": "user", "name": n}`, "", "n"},
+		{"'c'", "", "true"},
+		{"_", "(n > 3)", "{big:n}"},
+	}
+	if len(me.Arms) != len(expected) {
+		t.Fatalf("ParseProgram: expected %d arms, got %d", len(expected), len(me.Arms))
+	}
+	for i, arm := range me.Arms {
+		if arm.Pattern.String() != expected[i].pattern {
+			t.Errorf("arm %d: expected pattern %q, got %q", i, expected[i].pattern, arm.Pattern.String())
+		}
+		guard := ""
+		if arm.Guard != nil {
+			guard = arm.Guard.String()
+		}
+		if guard != expected[i].guard {
+			t.Errorf("arm %d: expected guard %q, got %q", i, expected[i].guard, guard)
+		}
+		if arm.Body.String() != expected[i].body {
+			t.Errorf("arm %d: expected body %q, got %q", i, expected[i].body, arm.Body.String())
+		}
+	}
+	if _, ok := me.Arms[4].Pattern.(*ast.HashPattern).Pairs[0].Target.(*ast.LiteralPattern); !ok {
+		t.Errorf("arm 4: expected a literal pattern for \"type\"")
+	}
+}
+
 func TestReturnStatement(t *testing.T) {
 	input := `
 	return 5;
@@ -157,6 +254,90 @@ func TestIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`1_000_000;`, 1000000},
+		{`010;`, 10},
+		{`0xff;`, 255},
+		{`0XFF_FF;`, 65535},
+		{`0o755;`, 493},
+		{`0b1010_0101;`, 165},
+		{`9223372036854775807;`, 9223372036854775807},
+		{`0x7fff_ffff_ffff_ffff;`, 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserError(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("ParseProgram: expected 1 statements, got %d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("ParseProgram: expected a ExpressionStatement, got %T", program.Statements[0])
+		}
+		testIntegerLiteral(t, stmt.Expression, tt.expected)
+	}
+}
+
+func TestIntegerLiteralOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 9223372036854775808;", "1:9: Integer literal 9223372036854775808 overflows int64"},
+		{"let mask =\n  0xffff_ffff_ffff_ffff;", "2:3: Integer literal 0xffff_ffff_ffff_ffff overflows int64"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) == 0 {
+			t.Fatalf("ParseProgram: expected errors for %q, got none", tt.input)
+		}
+		if errs[0].Error() != tt.expected {
+			t.Errorf("ParseProgram: expected error %q, got %q", tt.expected, errs[0].Error())
+		}
+	}
+}
+
+func TestFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{`1.5;`, 1.5},
+		{`0.125;`, 0.125},
+		{`1e3;`, 1000},
+		{`2.5E-2;`, 0.025},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserError(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("ParseProgram: expected 1 statements, got %d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("ParseProgram: expected a ExpressionStatement, got %T", program.Statements[0])
+		}
+		testFloatLiteral(t, stmt.Expression, tt.expected)
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input        string
@@ -165,6 +346,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{`!5;`, "!", 5},
 		{`-15;`, "-", 15},
+		{`~15;`, "~", 15},
 	}
 
 	for _, tt := range prefixTests {
@@ -198,6 +380,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 		rightValue interface{}
 	}{
 		{`5 + 5;`, 5, "+", 5},
+		{`1.5 * 2;`, 1.5, "*", 2},
 		{`5 - 5;`, 5, "-", 5},
 		{`5 * 5;`, 5, "*", 5},
 		{`5 / 5;`, 5, "/", 5},
@@ -208,6 +391,17 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{`true == true;`, true, "==", true},
 		{`true != false;`, true, "!=", false},
 		{`false == false;`, false, "==", false},
+		{`5 % 5;`, 5, "%", 5},
+		{`5 <= 5;`, 5, "<=", 5},
+		{`5 >= 5;`, 5, ">=", 5},
+		{`5 ** 5;`, 5, "**", 5},
+		{`5 & 5;`, 5, "&", 5},
+		{`5 | 5;`, 5, "|", 5},
+		{`5 ^ 5;`, 5, "^", 5},
+		{`5 << 5;`, 5, "<<", 5},
+		{`5 >> 5;`, 5, ">>", 5},
+		{`true && false;`, true, "&&", false},
+		{`a || b;`, "a", "||", "b"},
 	}
 
 	for _, tt := range infixTest {
@@ -286,6 +480,106 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			input:    `3 + 4 * 5 == 3 * 1 + 4 * 5`,
 			expected: `((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))`,
 		},
+		{
+			input:    `a * b % c`,
+			expected: "((a * b) % c)",
+		},
+		{
+			input:    `a + b % c`,
+			expected: "(a + (b % c))",
+		},
+		{
+			input:    `a <= b == c >= d`,
+			expected: "((a <= b) == (c >= d))",
+		},
+		{
+			input:    `2 ** 3 ** 2`,
+			expected: "(2 ** (3 ** 2))",
+		},
+		{
+			input:    `a = b = c + 1`,
+			expected: "(a = (b = (c + 1)))",
+		},
+		{
+			input:    `x += y || z`,
+			expected: "(x += (y || z))",
+		},
+		{
+			input:    `data |> filter(isValid) |> map(normalize) |> len`,
+			expected: "(((data |> filter(isValid)) |> map(normalize)) |> len)",
+		},
+		{
+			input:    `a || b |> f(c + 1)`,
+			expected: "((a || b) |> f((c + 1)))",
+		},
+		{
+			input:    `x = y |> f`,
+			expected: "(x = (y |> f))",
+		},
+		{
+			input:    `h.a.b = c += 1`,
+			expected: "(((h.a).b) = (c += 1))",
+		},
+		{
+			input:    `-a.b.c`,
+			expected: "(-((a.b).c))",
+		},
+		{
+			input:    `xs.push(1)[0] + h.f(2)`,
+			expected: "(((xs.push)(1)[0]) + (h.f)(2))",
+		},
+		{
+			input:    `a[i + 1] = b[0] * 2`,
+			expected: "((a[(i + 1)]) = ((b[0]) * 2))",
+		},
+		{
+			input:    `-2 ** 2`,
+			expected: "(-(2 ** 2))",
+		},
+		{
+			input:    `a * b ** c`,
+			expected: "(a * (b ** c))",
+		},
+		{
+			input:    `a | b ^ c & d`,
+			expected: "(a | (b ^ (c & d)))",
+		},
+		{
+			input:    `x & 1 == 0`,
+			expected: "((x & 1) == 0)",
+		},
+		{
+			input:    `1 << a + b`,
+			expected: "(1 << (a + b))",
+		},
+		{
+			input:    `a & b << c`,
+			expected: "(a & (b << c))",
+		},
+		{
+			input:    `~a & b`,
+			expected: "((~a) & b)",
+		},
+		{
+			input:    `a < b | c`,
+			expected: "(a < (b | c))",
+		},
+		{
+			input:    `a || b && c`,
+			expected: "(a || (b && c))",
+		},
+		{
+			input:    `a && b || c && d`,
+			expected: "((a && b) || (c && d))",
+		},
+		{
+			input:    `a == b && c != d`,
+			expected: "((a == b) && (c != d))",
+		},
+		{
+			input:    `!a || b < c`,
+			expected: "((!a) || (b < c))",
+		},
 		{
 			input:    `true`,
 			expected: "true",
@@ -444,6 +738,106 @@ func TestIfElseExpression(t *testing.T) {
 	testIdentifier(t, alternative.Expression, "y")
 }
 
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { if (x == 5) { break; } continue }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserError(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("ParseProgram: expected 1 statements, got %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("ParseProgram: expected a WhileStatement, got %T", program.Statements[0])
+	}
+
+	testInfixExpression(t, stmt.Condition, "x", "<", 10)
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("ParseProgram: expected 2 body statements, got %d", len(stmt.Body.Statements))
+	}
+
+	ifExp, ok := stmt.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("ParseProgram: expected an IfExpression, got %T", stmt.Body.Statements[0])
+	}
+	if _, ok := ifExp.Consequence.Statements[0].(*ast.BreakStatement); !ok {
+		t.Fatalf("ParseProgram: expected a BreakStatement, got %T", ifExp.Consequence.Statements[0])
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Fatalf("ParseProgram: expected a ContinueStatement, got %T", stmt.Body.Statements[1])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		key      string
+		value    string
+		expected string
+	}{
+		{`for (x in arr) { x }`, "", "x", "for (x in arr) x"},
+		{`for (k, v in {"a": 1}) { break; }`, "k", "v", "for (k, v in {a:1}) break;"},
+		{`for (c in "abc" + s) { continue }`, "", "c", "for (c in (abc + s)) continue;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserError(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("ParseProgram: expected 1 statements, got %d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("ParseProgram: expected a ForStatement, got %T", program.Statements[0])
+		}
+		if tt.key == "" && stmt.Key != nil {
+			t.Errorf("ParseProgram: expected no key, got %s", stmt.Key)
+		}
+		if tt.key != "" {
+			testIdentifier(t, stmt.Key, tt.key)
+		}
+		testIdentifier(t, stmt.Value, tt.value)
+		if stmt.String() != tt.expected {
+			t.Errorf("ParseProgram: expected %q, got %q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside of a loop"},
+		{"if (true) { continue }", "1:13: continue outside of a loop"},
+		{"while (true) { let f = fn() { break; }; }", "1:31: break outside of a loop"},
+		{"for (x in xs) { }; continue;", "1:20: continue outside of a loop"},
+		{"while (c) { fn(a = if (x) { break }) { a } }", "1:29: break outside of a loop"},
+		{"while (c) { (a = if (x) { break }) => a; break }", "1:27: break outside of a loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) != 1 {
+			t.Fatalf("ParseProgram: expected 1 error for %q, got %v", tt.input, errs)
+		}
+		if errs[0].Error() != tt.expected {
+			t.Errorf("ParseProgram: expected error %q, got %q", tt.expected, errs[0].Error())
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 	l := lexer.New(input)
@@ -515,6 +909,121 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestNamedArgumentParsing(t *testing.T) {
+	input := `connect("db", port: 5432, user: "admin" + suffix);`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserError(t, p)
+
+	ce, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("ParseProgram: expected a CallExpression, got %T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if len(ce.Arguments) != 1 || len(ce.NamedArguments) != 2 {
+		t.Fatalf("ParseProgram: expected 1 positional and 2 named arguments, got %d and %d", len(ce.Arguments), len(ce.NamedArguments))
+	}
+	testStringLiteral(t, ce.Arguments[0], "db")
+	testIdentifier(t, ce.NamedArguments[0].Name, "port")
+	testIntegerLiteral(t, ce.NamedArguments[0].Value, 5432)
+	testIdentifier(t, ce.NamedArguments[1].Name, "user")
+
+	expected := `connect(db, port: 5432, user: (admin + suffix))`
+	if ce.String() != expected {
+		t.Errorf("ParseProgram: expected %q, got %q", expected, ce.String())
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		params   int
+		rest     string
+	}{
+		{"fn(a, b = 10) {}", "fn(a, b = 10) ", 2, ""},
+		{"fn(a = 1 + 2, b = a) {}", "fn(a = (1 + 2), b = a) ", 2, ""},
+		{"fn(first, ...rest) {}", "fn(first, ...rest) ", 1, "rest"},
+		{"fn(...args) {}", "fn(...args) ", 0, "args"},
+		{"fn(a, b = 2, ...c) {}", "fn(a, b = 2, ...c) ", 2, "c"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserError(t, p)
+
+		fl := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if fl.String() != tt.expected {
+			t.Errorf("ParseProgram: expected %q, got %q", tt.expected, fl.String())
+		}
+		if len(fl.Parameters) != tt.params || len(fl.Defaults) != tt.params {
+			t.Errorf("ParseProgram: expected %d parameters, got %d (%d defaults)", tt.params, len(fl.Parameters), len(fl.Defaults))
+		}
+		if tt.rest == "" && fl.Rest != nil {
+			t.Errorf("ParseProgram: unexpected rest parameter %s", fl.Rest)
+		}
+		if tt.rest != "" {
+			testIdentifier(t, fl.Rest, tt.rest)
+		}
+	}
+}
+
+func TestArrowFunctionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x => x * 2", "fn(x) (x * 2)"},
+		{"(x, y) => x + y", "fn(x, y) (x + y)"},
+		{"() => 1", "fn() 1"},
+		{"(x) => { let y = x; y }", "fn(x) let y = x;y"},
+		{"(a, b = 10, ...rest) => a", "fn(a, b = 10, ...rest) a"},
+		{"(f = x => x) => f(1)", "fn(f = fn(x) x) f(1)"},
+		{"map(xs, x => x + 1)", "map(xs, fn(x) (x + 1))"},
+		{"xs |> map((x, i) => x * i)", "(xs |> map(fn(x, i) (x * i)))"},
+		{"x => y => x + y", "fn(x) fn(y) (x + y)"},
+		{"(x) * 2", "(x * 2)"},
+		{"(a + b) * c", "((a + b) * c)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserError(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	guards := []struct {
+		input    string
+		expected string
+	}{
+		{"match (v) { x if ok => x }", "ok"},
+		{"match (v) { x if (ok) => x }", "ok"},
+		{"match (v) { x if any(xs, y => y > x) => x }", "any(xs, fn(y) (y > x))"},
+	}
+
+	for _, tt := range guards {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserError(t, p)
+
+		match := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+		if match.Arms[0].Guard.String() != tt.expected {
+			t.Errorf("%s: expected guard %q, got %q", tt.input, tt.expected, match.Arms[0].Guard.String())
+		}
+		if match.Arms[0].Body.String() != "x" {
+			t.Errorf("%s: expected body x, got %q", tt.input, match.Arms[0].Body.String())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 	l := lexer.New(input)
@@ -558,6 +1067,88 @@ func TestStringLiteralExpression(t *testing.T) {
 	testStringLiteral(t, stmt.Expression, "hello world")
 }
 
+func TestCharLiteralExpression(t *testing.T) {
+	input := `'本';`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserError(t, p)
+	if len(program.Statements) != 1 {
+		t.Fatalf("ParseProgram: expected 1 statements, got %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("ParseProgram: expected a ExpressionStatement, got %T", program.Statements[0])
+	}
+	ch, ok := stmt.Expression.(*ast.CharLiteral)
+	if !ok {
+		t.Fatalf("ParseProgram: expected a CharLiteral, got %T", stmt.Expression)
+	}
+	if ch.Value != '本' {
+		t.Fatalf("ParseProgram: expected '本', got %q", ch.Value)
+	}
+	if ch.String() != `'本'` {
+		t.Fatalf("ParseProgram: unexpected String() %s", ch.String())
+	}
+}
+
+func TestInterpolatedStringParsing(t *testing.T) {
+	input := `"Hello ${name}, you have ${count + 1} items";`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserError(t, p)
+	if len(program.Statements) != 1 {
+		t.Fatalf("ParseProgram: expected 1 statements, got %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("ParseProgram: expected a ExpressionStatement, got %T", program.Statements[0])
+	}
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("ParseProgram: expected an InterpolatedString, got %T", stmt.Expression)
+	}
+	if len(str.Parts) != 5 {
+		t.Fatalf("ParseProgram: expected 5 parts, got %d", len(str.Parts))
+	}
+	testStringLiteral(t, str.Parts[0], "Hello ")
+	testIdentifier(t, str.Parts[1], "name")
+	testStringLiteral(t, str.Parts[2], ", you have ")
+	testInfixExpression(t, str.Parts[3], "count", "+", 1)
+	testStringLiteral(t, str.Parts[4], " items")
+
+	if str.String() != `"Hello ${name}, you have ${(count + 1)} items"` {
+		t.Errorf("ParseProgram: unexpected String() %s", str.String())
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${} b"`, "1:6: Expected an expression inside ${...}"},
+		{`"a ${x b"`, "1:8: Expected next token to be INTERP_END, but got IDENT(b)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) == 0 {
+			t.Fatalf("ParseProgram: expected errors for %q, got none", tt.input)
+		}
+		if errs[0].Error() != tt.expected {
+			t.Errorf("ParseProgram: expected error %q, got %q", tt.expected, errs[0].Error())
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1 , 2 * 2, 3 + 3]"
 	l := lexer.New(input)
@@ -641,14 +1232,29 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		"three": 3,
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Fatalf("ParseProgram: expected a StringLiteral, got %T", key)
+			t.Fatalf("ParseProgram: expected a StringLiteral, got %T", pair.Key)
 		}
 
 		expectedValue := expected[literal.Value]
-		testIntegerLiteral(t, value, expectedValue)
+		testIntegerLiteral(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashLiteralOrder(t *testing.T) {
+	input := `{"c": 1, "a": 2, "b": 3, 1: 4}`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserError(t, p)
+
+	expected := `{c:1,a:2,b:3,1:4}`
+	for i := 0; i < 10; i++ {
+		if program.String() != expected {
+			t.Fatalf("ParseProgram: expected %s, got %s", expected, program.String())
+		}
 	}
 }
 
@@ -707,13 +1313,265 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Fatalf("ParseProgram: expected a StringLiteral, got %T", key)
+			t.Fatalf("ParseProgram: expected a StringLiteral, got %T", pair.Key)
 		}
 
 		test := tests[literal.Value]
-		test(value)
+		test(pair.Value)
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    "let x = 5;\nlet = 10;",
+			expected: "2:5: Expected next token to be IDENT, but got ASSIGN(=)",
+		},
+		{
+			input:    "let s = \"abc;",
+			expected: "1:9: Illegal token: unterminated string literal",
+		},
+		{
+			input:    "let s = \"a\\qb\";",
+			expected: "1:9: Illegal token: invalid escape sequence: \\q",
+		},
+		{
+			input:    "let a = 1 @ 2;",
+			expected: "1:11: Illegal token: unexpected character \"@\"",
+		},
+		{
+			input:    "add(1,\n  2;",
+			expected: "2:4: Expected next token to be RPAREN, but got SEMICOLON(;)",
+		},
+		{
+			input:    "a + b = 1;",
+			expected: "1:7: Cannot assign to (a + b)",
+		},
+		{
+			input:    "fn(a = 1, b) {}",
+			expected: "1:11: Parameter b without a default follows a parameter with one",
+		},
+		{
+			input:    "(a, 1) => a",
+			expected: "1:5: Expected next token to be IDENT, but got INT(1)",
+		},
+		{
+			input:    "(a = 1, b) => a",
+			expected: "1:9: Parameter b without a default follows a parameter with one",
+		},
+		{
+			input:    "((x)) => x",
+			expected: "1:7: Expected a prefix parse function for =>, but none was found",
+		},
+		{
+			input:    "(a, b)",
+			expected: "1:3: Expected next token to be RPAREN, but got COMMA(,)",
+		},
+		{
+			input:    "fn(...a, b) {}",
+			expected: "1:8: Expected next token to be RPAREN, but got COMMA(,)",
+		},
+		{
+			input:    "fn(a..b) {}",
+			expected: "1:5: Expected next token to be RPAREN, but got ILLEGAL(..)",
+		},
+		{
+			input:    "f(a: 1, 2);",
+			expected: "1:9: Positional argument after named arguments",
+		},
+		{
+			input:    "f(a: 1, a: 2);",
+			expected: "1:9: Duplicate named argument a",
+		},
+		{
+			input:    "let [a, 1] = xs;",
+			expected: "1:9: Expected a pattern, but got INT(1)",
+		},
+		{
+			input:    "let [...a, b] = xs;",
+			expected: "1:10: Expected next token to be RBRACKET, but got COMMA(,)",
+		},
+		{
+			input:    "let {\"a\"} = h;",
+			expected: "1:9: Expected next token to be COLON, but got RBRACE(})",
+		},
+		{
+			input:    "let {1: a} = h;",
+			expected: "1:6: Expected next token to be IDENT or STRING, but got INT(1)",
+		},
+		{
+			input:    "match (x) { 1 => 2 3 => 4 }",
+			expected: "1:20: Expected next token to be COMMA, but got INT(3)",
+		},
+		{
+			input:    "match (x) { -a => 1 }",
+			expected: "1:13: Expected a pattern, but got MINUS(-)",
+		},
+		{
+			input:    "match (x) { a 1 }",
+			expected: "1:15: Expected next token to be =>, but got INT(1)",
+		},
+		{
+			input:    "a.1;",
+			expected: "1:3: Expected next token to be IDENT, but got INT(1)",
+		},
+		{
+			input:    "f(x) = 1;",
+			expected: "1:6: Cannot assign to f(x)",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) == 0 {
+			t.Fatalf("ParseProgram: expected errors for %q, got none", tt.input)
+		}
+		if errs[0].Error() != tt.expected {
+			t.Errorf("ParseProgram: expected error %q, got %q", tt.expected, errs[0].Error())
+		}
+	}
+}
+
+func TestParseErrorDetails(t *testing.T) {
+	l := lexer.New("let x 5;")
+	p := New(l)
+	p.ParseProgram()
+
+	errs := p.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("ParseProgram: expected 1 error, got %v", errs)
+	}
+	err := errs[0]
+	if err.Pos.Line != 1 || err.Pos.Column != 7 {
+		t.Errorf("ParseError: expected position 1:7, got %s", err.Pos)
+	}
+	if len(err.Expected) != 1 || err.Expected[0] != token.ASSIGN {
+		t.Errorf("ParseError: expected [ASSIGN], got %v", err.Expected)
+	}
+	if err.Actual.Type != token.INT || err.Actual.Literal != "5" {
+		t.Errorf("ParseError: expected actual token INT(5), got %s", err.Actual)
+	}
+	if err.Message != "Expected next token to be ASSIGN, but got INT(5)" {
+		t.Errorf("ParseError: wrong message %q", err.Message)
+	}
+}
+
+func TestParseErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		errors     []string
+		statements int
+	}{
+		{
+			input:      "let = 5;\nlet y = 10;\nlet = 3;",
+			errors:     []string{"1:5: Expected next token to be IDENT, but got ASSIGN(=)", "3:5: Expected next token to be IDENT, but got ASSIGN(=)"},
+			statements: 1,
+		},
+		{
+			input:      "add(1, 2 3, 4);\nlet y = 2;",
+			errors:     []string{"1:10: Expected next token to be RPAREN, but got INT(3)"},
+			statements: 1,
+		},
+		{
+			input:      "let f = fn() { let = 1; let a = 2; };\nlet z = ;\nz;",
+			errors:     []string{"1:20: Expected next token to be IDENT, but got ASSIGN(=)", "2:9: Expected a prefix parse function for SEMICOLON, but none was found"},
+			statements: 2,
+		},
+		{
+			input:      "if (x) { let y = 1 + }\nlet z = 3;",
+			errors:     []string{"1:22: Expected a prefix parse function for RBRACE, but none was found"},
+			statements: 2,
+		},
+		{
+			input:      "if (x) { let h = {\"a\" 1}; y } else { z }; w",
+			errors:     []string{"1:23: Expected next token to be COLON, but got INT(1)"},
+			statements: 2,
+		},
+		{
+			input: "while (x { puts(1) }\nlet b = ;\nlet c = ;",
+			errors: []string{
+				"1:10: Expected next token to be RPAREN, but got LBRACE({)",
+				"2:9: Expected a prefix parse function for SEMICOLON, but none was found",
+				"3:9: Expected a prefix parse function for SEMICOLON, but none was found",
+			},
+			statements: 0,
+		},
+		{
+			input:      "if (x { let a = 1; } else { a }\nlet b = ;\nlet c = 3;",
+			errors:     []string{"1:7: Expected next token to be RPAREN, but got LBRACE({)", "2:9: Expected a prefix parse function for SEMICOLON, but none was found"},
+			statements: 1,
+		},
+		{
+			input:      "let f = fn(x { x }\nlet g = fn(y) { y }\nf",
+			errors:     []string{"1:14: Expected next token to be RPAREN, but got LBRACE({)"},
+			statements: 2,
+		},
+		{
+			input:      "}; let a = 1;",
+			errors:     []string{"1:1: Expected a prefix parse function for RBRACE, but none was found"},
+			statements: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) != len(tt.errors) {
+			t.Errorf("ParseProgram: expected %d errors for %q, got %v", len(tt.errors), tt.input, errs)
+			continue
+		}
+		for i, err := range errs {
+			if err.Error() != tt.errors[i] {
+				t.Errorf("ParseProgram: expected error %q, got %q", tt.errors[i], err.Error())
+			}
+		}
+		if len(program.Statements) != tt.statements {
+			t.Errorf("ParseProgram: expected %d statements for %q, got %d", tt.statements, tt.input, len(program.Statements))
+		}
+	}
+}
+
+func TestProgramComments(t *testing.T) {
+	input := `// add two numbers
+let add = fn(x, y) {
+	x + y; /* the sum */
+};`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserError(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("ParseProgram: expected 1 statements, got %d", len(program.Statements))
+	}
+	if !testLetStatement(t, program.Statements[0], "add") {
+		return
+	}
+
+	expected := []string{" add two numbers", " the sum "}
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("ParseProgram: expected %d comments, got %d", len(expected), len(program.Comments))
+	}
+	for i, c := range program.Comments {
+		if c.Text() != expected[i] {
+			t.Errorf("ParseProgram: expected comment %q, got %q", expected[i], c.Text())
+		}
+	}
+	if program.Comments[1].Pos().Line != 3 {
+		t.Errorf("ParseProgram: expected comment on line 3, got %s", program.Comments[1].Pos())
 	}
 }
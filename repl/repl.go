@@ -41,10 +41,10 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParseError(out io.Writer, errors []string) {
+func printParseError(out io.Writer, errors []*parser.ParseError) {
 	io.WriteString(out, "Woop! We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
 	}
 }
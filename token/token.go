@@ -8,9 +8,43 @@ func (tt TokenType) String() string {
 	return string(tt)
 }
 
+// Position describes a location in the source. Line and Column are 1-based,
+// Offset is the 0-based byte offset from the beginning of the input.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// IsValid reports whether the position has been set.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// Token is a lexeme of the source. Literal is its text, except for literals
+// whose value differs from how they are written, such as strings with escape
+// sequences. An ILLEGAL token keeps the offending source text in Literal and
+// says what is wrong with it in Message.
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+	Message string
 }
 
 func (t Token) String() string {
@@ -18,29 +52,58 @@ func (t Token) String() string {
 }
 
 const (
-	ILLEGAL   TokenType = "ILLEGAL"
-	EOF       TokenType = "EOF"
-	IDENT     TokenType = "IDENT"
-	INT       TokenType = "INT"
-	STRING    TokenType = "STRING"
-	ASSIGN    TokenType = "ASSIGN"
-	PLUS      TokenType = "PLUS"
-	MINUS     TokenType = "MINUS"
-	BANG      TokenType = "BANG"
-	ASTERISK  TokenType = "ASTERISK"
-	SLASH     TokenType = "SLASH"
-	MODULO    TokenType = "MODULO"
-	GT        TokenType = "GT"
-	LT        TokenType = "LT"
-	GTE       TokenType = "GTE"
-	LTE       TokenType = "LTE"
-	EQ        TokenType = "=="
-	NOT_EQ    TokenType = "!="
-	AND       TokenType = "AND"
-	OR        TokenType = "OR"
+	ILLEGAL TokenType = "ILLEGAL"
+	EOF     TokenType = "EOF"
+	IDENT   TokenType = "IDENT"
+	INT     TokenType = "INT"
+	FLOAT   TokenType = "FLOAT"
+	STRING  TokenType = "STRING"
+	CHAR    TokenType = "CHAR"
+
+	// An interpolated string "a${x}b${y}c" is lexed as INTERP_START(a), the
+	// tokens of x, INTERP_MID(b), the tokens of y and INTERP_END(c).
+	INTERP_START TokenType = "INTERP_START"
+	INTERP_MID   TokenType = "INTERP_MID"
+	INTERP_END   TokenType = "INTERP_END"
+
+	COMMENT  TokenType = "COMMENT"
+	ASSIGN   TokenType = "ASSIGN"
+	PLUS     TokenType = "PLUS"
+	MINUS    TokenType = "MINUS"
+	BANG     TokenType = "BANG"
+	ASTERISK TokenType = "ASTERISK"
+	SLASH    TokenType = "SLASH"
+	MODULO   TokenType = "MODULO"
+	POWER    TokenType = "POWER"
+	GT       TokenType = "GT"
+	LT       TokenType = "LT"
+	GTE      TokenType = "GTE"
+	LTE      TokenType = "LTE"
+	EQ       TokenType = "=="
+	NOT_EQ   TokenType = "!="
+	AND      TokenType = "&&"
+	OR       TokenType = "||"
+	PIPE     TokenType = "|>"
+
+	PLUS_ASSIGN     TokenType = "+="
+	MINUS_ASSIGN    TokenType = "-="
+	ASTERISK_ASSIGN TokenType = "*="
+	SLASH_ASSIGN    TokenType = "/="
+	MODULO_ASSIGN   TokenType = "%="
+
+	BIT_AND     TokenType = "BIT_AND"
+	BIT_OR      TokenType = "BIT_OR"
+	BIT_XOR     TokenType = "BIT_XOR"
+	BIT_NOT     TokenType = "BIT_NOT"
+	SHIFT_LEFT  TokenType = "SHIFT_LEFT"
+	SHIFT_RIGHT TokenType = "SHIFT_RIGHT"
+
 	COMMA     TokenType = "COMMA"
 	SEMICOLON TokenType = "SEMICOLON"
 	COLON     TokenType = "COLON"
+	DOT       TokenType = "DOT"
+	ELLIPSIS  TokenType = "..."
+	ARROW     TokenType = "=>"
 	LPAREN    TokenType = "LPAREN"
 	RPAREN    TokenType = "RPAREN"
 	LBRACE    TokenType = "LBRACE"
@@ -54,18 +117,28 @@ const (
 	IF        TokenType = "IF"
 	ELSE      TokenType = "ELSE"
 	WHILE     TokenType = "WHILE"
+	FOR       TokenType = "FOR"
+	IN        TokenType = "IN"
+	MATCH     TokenType = "MATCH"
 	RETURN    TokenType = "RETURN"
+	BREAK     TokenType = "BREAK"
+	CONTINUE  TokenType = "CONTINUE"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"while":  WHILE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"match":    MATCH,
+	"return":   RETURN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 func LookupIdent(ident string) TokenType {